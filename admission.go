@@ -0,0 +1,242 @@
+package athena
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// AdmissionConfig configures an AdmissionController.
+type AdmissionConfig struct {
+	// Rate is the most queries per second that may be started, and Burst is
+	// how many may be started at once after a quiet period. Burst defaults
+	// to 1. A Rate of 0 doesn't limit the rate at all.
+	Rate  float64
+	Burst int
+
+	// MaxConcurrency is the most queries that may be running at once.
+	// Whenever Athena throttles a query, the limit is halved, down to
+	// MinConcurrency (which defaults to 1). It then grows back by roughly one
+	// for every limit's worth of queries that start without being throttled.
+	// A MaxConcurrency of 0 doesn't limit concurrency at all.
+	MaxConcurrency int
+	MinConcurrency int
+}
+
+// AdmissionStats is a snapshot of an AdmissionController's state.
+type AdmissionStats struct {
+	// QueueDepth is the number of queries waiting to be admitted.
+	QueueDepth int
+	// Running is the number of queries that have been admitted and are running.
+	Running int
+	// ConcurrencyLimit is the current concurrency limit, or 0 if there's none.
+	ConcurrencyLimit int
+
+	Admitted  int64
+	Throttled int64
+	// TotalWait is how long admitted queries have waited in total.
+	TotalWait time.Duration
+}
+
+// AdmissionController limits the rate and concurrency at which queries are
+// started on Athena, so that a process stays within the account's quotas.
+// It's set on Config.Admission, and every sql.DB opened with that Config
+// (or a copy of it) shares it. It's safe for concurrent use.
+//
+// Concurrency only counts queries run through database/sql. Client.StartQuery()
+// and Client.RunBatch() are rate limited and adapt to throttling, but their
+// queries don't hold a concurrency slot while they run.
+type AdmissionController struct {
+	cfg AdmissionConfig
+
+	mu      sync.Mutex
+	changed chan struct{}
+
+	tokens     float64
+	lastRefill time.Time
+
+	limit   float64
+	running int
+	stats   AdmissionStats
+}
+
+// NewAdmissionController returns an AdmissionController for cfg.
+func NewAdmissionController(cfg AdmissionConfig) *AdmissionController {
+	if cfg.Burst <= 0 {
+		cfg.Burst = 1
+	}
+	if cfg.MinConcurrency <= 0 {
+		cfg.MinConcurrency = 1
+	}
+	if cfg.MinConcurrency > cfg.MaxConcurrency && cfg.MaxConcurrency > 0 {
+		cfg.MinConcurrency = cfg.MaxConcurrency
+	}
+
+	return &AdmissionController{
+		cfg:        cfg,
+		changed:    make(chan struct{}),
+		tokens:     float64(cfg.Burst),
+		lastRefill: time.Now(),
+		limit:      float64(cfg.MaxConcurrency),
+	}
+}
+
+// Stats returns a snapshot of the controller's state.
+func (a *AdmissionController) Stats() AdmissionStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stats := a.stats
+	stats.Running = a.running
+	stats.ConcurrencyLimit = int(a.limit)
+	return stats
+}
+
+// acquire blocks until a query may be started. Unless it returns an error,
+// the caller must call release once the query has finished, with the error
+// it failed with, if any.
+func (a *AdmissionController) acquire(ctx context.Context) (release func(error), err error) {
+	start := time.Now()
+
+	a.mu.Lock()
+	a.stats.QueueDepth++
+	for {
+		var wait time.Duration
+		if a.cfg.MaxConcurrency == 0 || a.running < int(a.limit) {
+			wait = a.reserveToken(time.Now())
+			if wait == 0 {
+				a.running++
+				a.stats.QueueDepth--
+				a.stats.Admitted++
+				a.stats.TotalWait += time.Since(start)
+				a.mu.Unlock()
+				return a.release, nil
+			}
+		}
+		changed := a.changed
+		a.mu.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-changed:
+		case <-expired:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		a.mu.Lock()
+		if err != nil {
+			a.stats.QueueDepth--
+			a.mu.Unlock()
+			return nil, err
+		}
+	}
+}
+
+// reserveToken takes a token from the bucket, or returns how long until one
+// is available. It must be called with a.mu held.
+func (a *AdmissionController) reserveToken(now time.Time) time.Duration {
+	if a.cfg.Rate <= 0 {
+		return 0
+	}
+
+	a.tokens = math.Min(float64(a.cfg.Burst), a.tokens+now.Sub(a.lastRefill).Seconds()*a.cfg.Rate)
+	a.lastRefill = now
+	if a.tokens >= 1 {
+		a.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - a.tokens) / a.cfg.Rate * float64(time.Second))
+}
+
+func (a *AdmissionController) release(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.running--
+	a.adapt(err)
+}
+
+// throttled lowers the concurrency limit if err means Athena throttled a
+// request made on behalf of running queries, such as a poll. It's a no-op on
+// a nil controller.
+func (a *AdmissionController) throttled(err error) {
+	if a == nil || !IsThrottlingError(err) {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.adapt(err)
+}
+
+// adapt updates the concurrency limit after a request that failed with err,
+// if any. It must be called with a.mu held.
+func (a *AdmissionController) adapt(err error) {
+	throttled := IsThrottlingError(err)
+	if throttled {
+		a.stats.Throttled++
+	}
+	if a.cfg.MaxConcurrency > 0 {
+		if throttled {
+			a.limit = math.Max(float64(a.cfg.MinConcurrency), a.limit/2)
+		} else {
+			a.limit = math.Min(float64(a.cfg.MaxConcurrency), a.limit+1/a.limit)
+		}
+	}
+
+	// Wake up everyone waiting, as there may be room for them now.
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+// IsThrottlingError reports whether err, or an error it wraps, means Athena
+// rejected a request because too many are being made or too many queries are
+// running.
+func IsThrottlingError(err error) bool {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		switch aerr.Code() {
+		case "ThrottlingException", "TooManyRequestsException":
+			return true
+		}
+	}
+	return false
+}
+
+// isRetryableError reports whether a request that failed with err may
+// succeed if it's made again: it was throttled, or Athena failed internally.
+func isRetryableError(err error) bool {
+	if IsThrottlingError(err) {
+		return true
+	}
+	if failure, ok := err.(awserr.RequestFailure); ok && failure.StatusCode() >= 500 {
+		return true
+	}
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == "InternalServerException"
+	}
+	return false
+}
+
+// admit is acquire for an optional controller.
+func (a *AdmissionController) admit(ctx context.Context) (func(error), error) {
+	if a == nil {
+		return func(error) {}, nil
+	}
+	return a.acquire(ctx)
+}
@@ -0,0 +1,92 @@
+package athena
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmissionController_Concurrency(t *testing.T) {
+	a := NewAdmissionController(AdmissionConfig{MaxConcurrency: 2})
+	ctx := context.Background()
+
+	release1, err := a.acquire(ctx)
+	require.NoError(t, err)
+	release2, err := a.acquire(ctx)
+	require.NoError(t, err)
+
+	admitted := make(chan func(error))
+	go func() {
+		release, err := a.acquire(ctx)
+		assert.NoError(t, err)
+		admitted <- release
+	}()
+
+	select {
+	case <-admitted:
+		t.Fatal("admitted a query over the concurrency limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.Equal(t, AdmissionStats{QueueDepth: 1, Running: 2, ConcurrencyLimit: 2, Admitted: 2}, withoutWait(a.Stats()))
+
+	release1(nil)
+	release3 := <-admitted
+	release2(nil)
+	release3(nil)
+	assert.Equal(t, AdmissionStats{QueueDepth: 0, Running: 0, ConcurrencyLimit: 2, Admitted: 3}, withoutWait(a.Stats()))
+}
+
+func TestAdmissionController_Throttling(t *testing.T) {
+	a := NewAdmissionController(AdmissionConfig{MaxConcurrency: 8, MinConcurrency: 2})
+	throttled := awserr.New("TooManyRequestsException", "slow down", nil)
+
+	for _, expected := range []int{4, 2, 2} {
+		release, err := a.acquire(context.Background())
+		require.NoError(t, err)
+		release(throttled)
+		assert.Equal(t, expected, a.Stats().ConcurrencyLimit)
+	}
+	assert.Equal(t, int64(3), a.Stats().Throttled)
+
+	for i := 0; i < 40; i++ {
+		release, err := a.acquire(context.Background())
+		require.NoError(t, err)
+		release(nil)
+	}
+	assert.Equal(t, 8, a.Stats().ConcurrencyLimit, "limit should recover")
+}
+
+func TestAdmissionController_Rate(t *testing.T) {
+	a := NewAdmissionController(AdmissionConfig{Rate: 100, Burst: 2})
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		release, err := a.acquire(context.Background())
+		require.NoError(t, err)
+		release(nil)
+	}
+	assert.True(t, time.Since(start) >= 15*time.Millisecond, "only the burst should be admitted immediately")
+	assert.True(t, a.Stats().TotalWait > 0)
+}
+
+func TestAdmissionController_Cancel(t *testing.T) {
+	a := NewAdmissionController(AdmissionConfig{MaxConcurrency: 1})
+	release, err := a.acquire(context.Background())
+	require.NoError(t, err)
+	defer release(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = a.acquire(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 0, a.Stats().QueueDepth)
+}
+
+func withoutWait(stats AdmissionStats) AdmissionStats {
+	stats.TotalWait = 0
+	return stats
+}
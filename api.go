@@ -0,0 +1,239 @@
+package athena
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// AthenaAPI is the part of Athena's API the driver calls. Requests and
+// responses are the driver's own types, holding only what it sends and reads,
+// so that it doesn't depend on either AWS SDK's.
+//
+// The driver makes one from Config.Session by default. Set Config.API to use
+// another: NewV1API() wraps an SDK v1 client, and the athenav2 package an
+// SDK v2 one. Tests can implement it with a tiny fake.
+//
+// Errors should be, or wrap, the SDK v1's awserr.Error when they come from
+// AWS, so that the driver recognizes throttling.
+type AthenaAPI interface {
+	StartQueryExecution(ctx context.Context, input *StartQueryExecutionInput) (*StartQueryExecutionOutput, error)
+	StopQueryExecution(ctx context.Context, input *StopQueryExecutionInput) error
+	GetQueryExecution(ctx context.Context, input *GetQueryExecutionInput) (*QueryExecution, error)
+	BatchGetQueryExecution(ctx context.Context, input *BatchGetQueryExecutionInput) (*BatchGetQueryExecutionOutput, error)
+	GetQueryResults(ctx context.Context, input *GetQueryResultsInput) (*GetQueryResultsOutput, error)
+}
+
+// States of queries, as QueryExecutionStatus.State and QueryStatus.State
+// report them.
+const (
+	QueryStateQueued    = "QUEUED"
+	QueryStateRunning   = "RUNNING"
+	QueryStateSucceeded = "SUCCEEDED"
+	QueryStateFailed    = "FAILED"
+	QueryStateCancelled = "CANCELLED"
+)
+
+// Types of statements, as QueryExecution.StatementType and
+// QueryInfo.StatementType report them.
+const (
+	StatementTypeDDL     = "DDL"
+	StatementTypeDML     = "DML"
+	StatementTypeUtility = "UTILITY"
+)
+
+// StartQueryExecutionInput is a query to start, and how to run it.
+type StartQueryExecutionInput struct {
+	Query string
+
+	// Catalog and WorkGroup are Athena's defaults if they're empty.
+	Catalog   string
+	Database  string
+	WorkGroup string
+
+	OutputLocation      string
+	ExpectedBucketOwner string
+	S3ACLOption         string
+	// Encryption is nil if the results aren't encrypted.
+	Encryption *Encryption
+
+	ClientRequestToken string
+
+	// ResultReuseMaxAge, if set, lets Athena answer the query with the
+	// results of an identical one that ran at most this long ago.
+	ResultReuseMaxAge time.Duration
+}
+
+type StartQueryExecutionOutput struct {
+	QueryID string
+}
+
+type StopQueryExecutionInput struct {
+	QueryID string
+}
+
+type GetQueryExecutionInput struct {
+	QueryID string
+}
+
+// QueryExecution describes a query Athena ran, or is running.
+type QueryExecution struct {
+	QueryID string
+	Query   string
+	// StatementType is one of the StatementType* constants.
+	StatementType string
+
+	Database  string
+	WorkGroup string
+	// OutputLocation is the S3 URL of the file holding the query's results.
+	OutputLocation string
+
+	Status     QueryExecutionStatus
+	Statistics QueryExecutionStatistics
+}
+
+type QueryExecutionStatus struct {
+	// State is one of the QueryState* constants.
+	State              string
+	StateChangeReason  string
+	SubmissionDateTime time.Time
+	CompletionDateTime time.Time
+
+	// AthenaError is set if the query failed.
+	AthenaError *AthenaError
+}
+
+// AthenaError is why a query failed, as Athena classifies it.
+type AthenaError struct {
+	// ErrorCategory is 1 for system errors, 2 for user errors and 3 for
+	// others.
+	ErrorCategory int64
+	ErrorType     int64
+	ErrorMessage  string
+	Retryable     bool
+}
+
+type QueryExecutionStatistics struct {
+	DataScannedInBytes int64
+
+	QueryQueueTime        time.Duration
+	QueryPlanningTime     time.Duration
+	EngineExecutionTime   time.Duration
+	ServiceProcessingTime time.Duration
+	TotalExecutionTime    time.Duration
+
+	ResultReused bool
+
+	// DataManifestLocation is the S3 URL of the list of files the query
+	// wrote, e.g. with UNLOAD.
+	DataManifestLocation string
+}
+
+type BatchGetQueryExecutionInput struct {
+	QueryIDs []string
+}
+
+type BatchGetQueryExecutionOutput struct {
+	QueryExecutions []*QueryExecution
+	// Unprocessed holds the queries Athena couldn't describe, and why.
+	Unprocessed []UnprocessedQueryExecution
+}
+
+type UnprocessedQueryExecution struct {
+	QueryID      string
+	ErrorCode    string
+	ErrorMessage string
+}
+
+type GetQueryResultsInput struct {
+	QueryID   string
+	NextToken string
+	// MaxResults is Athena's default, 1000, if it's 0.
+	MaxResults int
+}
+
+// GetQueryResultsOutput is a page of a query's results.
+type GetQueryResultsOutput struct {
+	Columns []ColumnInfo
+	// Rows holds the values of each row, as Athena renders them, or nil for
+	// nulls. The first page of the results of a DML statement starts with a
+	// header row of the columns' names.
+	Rows [][]*string
+	// NextToken is "" on the last page.
+	NextToken string
+}
+
+// ColumnInfo describes a column of a query's results.
+type ColumnInfo struct {
+	Name string
+	Type string
+
+	CatalogName string
+	SchemaName  string
+	TableName   string
+
+	// Precision and Scale are those of decimal columns.
+	Precision int64
+	Scale     int64
+}
+
+// S3API is the part of S3's API the driver calls, to read the files written
+// by queries, and delete those of queries run with ResultModeUnload. Like
+// AthenaAPI, it's in terms of the driver's own types.
+//
+// The driver makes one from Config.Session by default. Set Config.S3 to use
+// another: NewV1S3API() wraps an SDK v1 client, and the athenav2 package an
+// SDK v2 one.
+type S3API interface {
+	GetObject(ctx context.Context, input *S3GetObjectInput) (*S3GetObjectOutput, error)
+	HeadObject(ctx context.Context, input *S3HeadObjectInput) (*S3HeadObjectOutput, error)
+	ListObjectsV2(ctx context.Context, input *S3ListObjectsInput) (*S3ListObjectsOutput, error)
+	DeleteObjects(ctx context.Context, input *S3DeleteObjectsInput) (*S3DeleteObjectsOutput, error)
+}
+
+type S3GetObjectInput struct {
+	Bucket string
+	Key    string
+	// Range, if set, is the HTTP range of bytes to get, e.g. "bytes=0-99".
+	Range string
+}
+
+type S3GetObjectOutput struct {
+	Body io.ReadCloser
+}
+
+type S3HeadObjectInput struct {
+	Bucket string
+	Key    string
+}
+
+type S3HeadObjectOutput struct {
+	ContentLength int64
+}
+
+type S3ListObjectsInput struct {
+	Bucket            string
+	Prefix            string
+	ContinuationToken string
+}
+
+type S3ListObjectsOutput struct {
+	Keys []string
+	// NextContinuationToken is "" if there are no more objects.
+	NextContinuationToken string
+}
+
+type S3DeleteObjectsInput struct {
+	Bucket string
+	Keys   []string
+}
+
+type S3DeleteObjectsOutput struct {
+	// Errors holds the objects that couldn't be deleted.
+	Errors []S3DeleteError
+}
+
+type S3DeleteError struct {
+	Key     string
+	Message string
+}
@@ -0,0 +1,643 @@
+package athena
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/decimal128"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/segmentio/go-athena/presto"
+)
+
+// QueryArrow runs a query, waits for it to finish and returns its results as
+// Arrow record batches, without converting them to driver.Values. Arguments
+// are bound the same way as in db.Query(). The reader must be released.
+//
+// With ResultModeAPI, batches are read from the CSV file Athena writes the
+// results of SELECT queries to, if Config.S3 or Config.Session is set, and
+// otherwise each holds a page of results fetched from Athena's API. The
+// schema is made from the columns' Athena types: integers, floating point
+// numbers, decimals, booleans, dates, timestamps (in milliseconds, UTC for
+// timestamp with time zone) and varbinary have Arrow types of their own;
+// other types, such as array, map and row, are strings rendered by Athena.
+// With ResultModeUnload, batches are read straight from the Parquet files,
+// and releasing the reader deletes them.
+func (c *Client) QueryArrow(ctx context.Context, query string, args ...interface{}) (array.RecordReader, error) {
+	if len(args) > 0 {
+		var err error
+		query, err = presto.ValidateAndFormatSql(query, args...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.conn.resultModeFor(ctx) == ResultModeUnload && presto.IsQuery(query) {
+		rows, err := c.conn.runUnload(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		return newUnloadRecordReader(rows.(*unloadRows)), nil
+	}
+
+	ctx, execution, err := c.conn.run(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if location := csvResultLocation(execution); location != "" && c.conn.s3 != nil {
+		reader, err := c.conn.openCSVRecordReader(ctx, execution, location, memory.DefaultAllocator)
+		if err != nil {
+			return nil, err
+		}
+		return reader, nil
+	}
+	rows, err := c.conn.openRows(ctx, execution, hasHeaderRow(execution))
+	if err != nil {
+		return nil, err
+	}
+	return newPageRecordReader(rows, memory.DefaultAllocator), nil
+}
+
+// recordReader implements the reference counting of an array.RecordReader,
+// calling release once it drops to 0.
+type recordReader struct {
+	refs    atomic.Int64
+	release func()
+}
+
+func (r *recordReader) Retain() {
+	r.refs.Add(1)
+}
+
+func (r *recordReader) Release() {
+	if r.refs.Add(-1) == 0 {
+		r.release()
+	}
+}
+
+// pageRecordReader makes a record batch of each page of results.
+type pageRecordReader struct {
+	recordReader
+	rows    *rows
+	mem     memory.Allocator
+	schema  *arrow.Schema
+	columns []ColumnInfo
+	record  arrow.RecordBatch
+	err     error
+}
+
+func newPageRecordReader(r *rows, mem memory.Allocator) *pageRecordReader {
+	columns := r.out.Columns
+	pr := &pageRecordReader{
+		rows:    r,
+		mem:     mem,
+		schema:  arrowSchema(columns),
+		columns: columns,
+	}
+	pr.refs.Store(1)
+	pr.release = func() {
+		pr.releaseRecord()
+		pr.rows.Close()
+	}
+	return pr
+}
+
+func (r *pageRecordReader) Schema() *arrow.Schema { return r.schema }
+
+func (r *pageRecordReader) RecordBatch() arrow.RecordBatch { return r.record }
+
+// Deprecated: Use RecordBatch instead.
+func (r *pageRecordReader) Record() arrow.RecordBatch { return r.record }
+
+func (r *pageRecordReader) Err() error { return r.err }
+
+func (r *pageRecordReader) releaseRecord() {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+}
+
+// Next makes a record batch of the rows left on the current page, fetching
+// the next one first if they've all been read.
+func (r *pageRecordReader) Next() bool {
+	r.releaseRecord()
+	if r.err != nil {
+		return false
+	}
+
+	b := array.NewRecordBuilder(r.mem, r.schema)
+	defer b.Release()
+
+	n := 0
+	for {
+		row, err := r.rows.nextRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			r.err = err
+			return false
+		}
+
+		for i, value := range row {
+			if err := appendArrowValue(b.Field(i), &r.columns[i], value); err != nil {
+				r.err = err
+				return false
+			}
+		}
+		n++
+
+		if len(r.rows.out.Rows) == 0 {
+			break
+		}
+	}
+	if n == 0 {
+		return false
+	}
+
+	r.record = b.NewRecordBatch()
+	return true
+}
+
+// csvRecordBatchSize is the most rows in a batch read from a CSV file.
+const csvRecordBatchSize = 64 * 1024
+
+// csvResultLocation returns the S3 URL of the CSV file holding the results of
+// execution, or "" if they aren't in one. Only queries with a header row, such
+// as SELECT, have their results written as CSV.
+func csvResultLocation(execution *QueryExecution) string {
+	if !hasHeaderRow(execution) || !strings.HasSuffix(execution.OutputLocation, ".csv") {
+		return ""
+	}
+	return execution.OutputLocation
+}
+
+// csvRecordReader makes record batches of the rows of a CSV result file.
+type csvRecordReader struct {
+	recordReader
+	body    io.ReadCloser
+	csv     *bufio.Reader
+	mem     memory.Allocator
+	schema  *arrow.Schema
+	columns []ColumnInfo
+	record  arrow.RecordBatch
+	err     error
+
+	ctx   context.Context
+	hooks Hooks
+	info  QueryInfo
+	pages int
+	read  int
+
+	maxRows       int64
+	stopAtMaxRows bool
+}
+
+// openCSVRecordReader starts reading the CSV file at location, which holds
+// the results of execution. The columns' types are fetched from Athena, as
+// the file only has their names. Each batch read from it is a page to Hooks,
+// and MaxRows applies as to pages of results.
+func (c *conn) openCSVRecordReader(ctx context.Context, execution *QueryExecution, location string, mem memory.Allocator) (*csvRecordReader, error) {
+	hooks, info := c.hooksFor(ctx), newQueryInfo(execution)
+	columns, body, err := c.openCSVResults(ctx, execution, location)
+	if err != nil {
+		// There's no reader to release, so the results are closed now.
+		hooks.close(ctx, info, 0)
+		return nil, err
+	}
+
+	cr := &csvRecordReader{
+		body:    body,
+		csv:     bufio.NewReaderSize(body, 64*1024),
+		mem:     mem,
+		schema:  arrowSchema(columns),
+		columns: columns,
+		ctx:     ctx,
+		hooks:   hooks,
+		info:    info,
+
+		maxRows:       c.maxRowsFor(ctx),
+		stopAtMaxRows: c.stopAtMaxRows,
+	}
+	cr.refs.Store(1)
+	cr.release = func() {
+		cr.releaseRecord()
+		cr.body.Close()
+		cr.hooks.close(cr.ctx, cr.info, cr.read)
+	}
+
+	// Skip the header row.
+	if _, err := readCSVRecord(cr.csv); err != nil && err != io.EOF {
+		cr.Release()
+		return nil, fmt.Errorf("reading %s: %w", location, err)
+	}
+	return cr, nil
+}
+
+// openCSVResults fetches the columns of execution's results and starts
+// downloading the CSV file at location they're in.
+func (c *conn) openCSVResults(ctx context.Context, execution *QueryExecution, location string) ([]ColumnInfo, io.ReadCloser, error) {
+	out, err := c.athena.GetQueryResults(ctx, &GetQueryResultsInput{
+		QueryID:    execution.QueryID,
+		MaxResults: 1,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bucket, key, err := parseS3URL(location)
+	if err != nil {
+		return nil, nil, err
+	}
+	object, err := c.s3.GetObject(ctx, &S3GetObjectInput{Bucket: bucket, Key: key})
+	if err != nil {
+		return nil, nil, err
+	}
+	return out.Columns, object.Body, nil
+}
+
+func (r *csvRecordReader) Schema() *arrow.Schema { return r.schema }
+
+func (r *csvRecordReader) RecordBatch() arrow.RecordBatch { return r.record }
+
+// Deprecated: Use RecordBatch instead.
+func (r *csvRecordReader) Record() arrow.RecordBatch { return r.record }
+
+func (r *csvRecordReader) Err() error { return r.err }
+
+func (r *csvRecordReader) releaseRecord() {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+}
+
+// Next makes a record batch of the next rows of the file, up to MaxRows.
+func (r *csvRecordReader) Next() bool {
+	r.releaseRecord()
+	if r.err != nil {
+		return false
+	}
+
+	size := csvRecordBatchSize
+	if r.maxRows > 0 {
+		left := r.maxRows - int64(r.read)
+		if left <= 0 {
+			r.err = r.rowLimitReached()
+			return false
+		}
+		size = int(min(left, int64(size)))
+	}
+
+	r.pages++
+	page := PageInfo{Number: r.pages, StartedAt: time.Now()}
+	record, err := r.readBatch(size)
+	page.Duration = time.Since(page.StartedAt)
+	page.Err = err
+	if record != nil {
+		page.Rows = int(record.NumRows())
+	}
+	if err != nil || record != nil {
+		r.hooks.page(r.ctx, r.info, page)
+	}
+	if err != nil {
+		r.err = err
+		return false
+	}
+	if record == nil {
+		return false
+	}
+
+	r.read += page.Rows
+	r.record = record
+	return true
+}
+
+// readBatch reads up to size rows of the file into a record batch. It
+// returns nil if there are none left.
+func (r *csvRecordReader) readBatch(size int) (arrow.RecordBatch, error) {
+	b := array.NewRecordBuilder(r.mem, r.schema)
+	defer b.Release()
+
+	n := 0
+	for n < size {
+		values, err := readCSVRecord(r.csv)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != len(r.columns) {
+			return nil, fmt.Errorf("expected %d values in a row of results, not %d", len(r.columns), len(values))
+		}
+
+		for i, value := range values {
+			if err := appendArrowValue(b.Field(i), &r.columns[i], value); err != nil {
+				return nil, err
+			}
+		}
+		n++
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	return b.NewRecordBatch(), nil
+}
+
+// rowLimitReached returns what reading a row past MaxRows does, nil if there
+// are no more rows anyway.
+func (r *csvRecordReader) rowLimitReached() error {
+	if r.stopAtMaxRows {
+		return nil
+	}
+	if _, err := readCSVRecord(r.csv); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	return &ErrRowLimitExceeded{QueryID: r.info.QueryID, MaxRows: r.maxRows}
+}
+
+// readCSVRecord reads a row of an Athena CSV result file. Athena quotes every
+// value, so an empty unquoted value is a null, returned as nil, while an empty
+// string is quoted. It returns io.EOF if there are no more rows.
+func readCSVRecord(r *bufio.Reader) ([]*string, error) {
+	if _, err := r.Peek(1); err != nil {
+		return nil, err
+	}
+
+	var values []*string
+	var value strings.Builder
+	for {
+		value.Reset()
+		c, err := r.ReadByte()
+		quoted := err == nil && c == '"'
+		if quoted {
+			// Read up to the closing quote, unescaping doubled ones.
+			for {
+				if c, err = r.ReadByte(); err != nil {
+					if err == io.EOF {
+						err = io.ErrUnexpectedEOF
+					}
+					return nil, err
+				}
+				if c == '"' {
+					if c, err = r.ReadByte(); err != nil || c != '"' {
+						break
+					}
+				}
+				value.WriteByte(c)
+			}
+		}
+		// Read up to the end of the value.
+		for err == nil && c != ',' && c != '\n' {
+			if !quoted {
+				value.WriteByte(c)
+			} else if c != '\r' {
+				return nil, fmt.Errorf("unexpected %q after a quoted value", c)
+			}
+			c, err = r.ReadByte()
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		switch v := strings.TrimSuffix(value.String(), "\r"); {
+		case quoted:
+			s := value.String()
+			values = append(values, &s)
+		case v == "":
+			values = append(values, nil)
+		default:
+			values = append(values, &v)
+		}
+		if err == io.EOF || c == '\n' {
+			return values, nil
+		}
+	}
+}
+
+// arrowSchema returns the schema of record batches of columns.
+func arrowSchema(columns []ColumnInfo) *arrow.Schema {
+	fields := make([]arrow.Field, len(columns))
+	for i, col := range columns {
+		fields[i] = arrow.Field{Name: col.Name, Type: arrowType(&col), Nullable: true}
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// arrowType returns the Arrow type that values of col are read as.
+func arrowType(col *ColumnInfo) arrow.DataType {
+	switch col.Type {
+	case "tinyint":
+		return arrow.PrimitiveTypes.Int8
+	case "smallint":
+		return arrow.PrimitiveTypes.Int16
+	case "integer":
+		return arrow.PrimitiveTypes.Int32
+	case "bigint":
+		return arrow.PrimitiveTypes.Int64
+	case "float", "real":
+		return arrow.PrimitiveTypes.Float32
+	case "double":
+		return arrow.PrimitiveTypes.Float64
+	case "decimal":
+		precision := int32(col.Precision)
+		if precision <= 0 || precision > 38 {
+			precision = 38
+		}
+		return &arrow.Decimal128Type{Precision: precision, Scale: int32(col.Scale)}
+	case "boolean":
+		return arrow.FixedWidthTypes.Boolean
+	case "date":
+		return arrow.FixedWidthTypes.Date32
+	case "timestamp":
+		return &arrow.TimestampType{Unit: arrow.Millisecond}
+	case "timestamp with time zone":
+		return &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}
+	case "varbinary":
+		return arrow.BinaryTypes.Binary
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+// appendArrowValue parses Athena's text rendering of a value of col and
+// appends it to b, which was made for arrowType(col).
+func appendArrowValue(b array.Builder, col *ColumnInfo, value *string) error {
+	if value == nil {
+		b.AppendNull()
+		return nil
+	}
+
+	s := *value
+	var err error
+	switch b := b.(type) {
+	case *array.Int8Builder:
+		var v int64
+		if v, err = strconv.ParseInt(s, 10, 8); err == nil {
+			b.Append(int8(v))
+		}
+	case *array.Int16Builder:
+		var v int64
+		if v, err = strconv.ParseInt(s, 10, 16); err == nil {
+			b.Append(int16(v))
+		}
+	case *array.Int32Builder:
+		var v int64
+		if v, err = strconv.ParseInt(s, 10, 32); err == nil {
+			b.Append(int32(v))
+		}
+	case *array.Int64Builder:
+		var v int64
+		if v, err = strconv.ParseInt(s, 10, 64); err == nil {
+			b.Append(v)
+		}
+	case *array.Float32Builder:
+		var v float64
+		if v, err = strconv.ParseFloat(s, 32); err == nil {
+			b.Append(float32(v))
+		}
+	case *array.Float64Builder:
+		var v float64
+		if v, err = strconv.ParseFloat(s, 64); err == nil {
+			b.Append(v)
+		}
+	case *array.Decimal128Builder:
+		t := b.Type().(*arrow.Decimal128Type)
+		var v decimal128.Num
+		if v, err = decimal128.FromString(s, t.Precision, t.Scale); err == nil {
+			b.Append(v)
+		}
+	case *array.BooleanBuilder:
+		var v bool
+		if v, err = strconv.ParseBool(s); err == nil {
+			b.Append(v)
+		}
+	case *array.Date32Builder:
+		var v time.Time
+		if v, err = time.Parse(DateLayout, s); err == nil {
+			b.Append(arrow.Date32FromTime(v))
+		}
+	case *array.TimestampBuilder:
+		layout := TimestampLayout
+		if col.Type == "timestamp with time zone" {
+			layout = TimestampWithTimeZoneLayout
+		}
+		var v time.Time
+		if v, err = time.Parse(layout, s); err == nil {
+			var ts arrow.Timestamp
+			if ts, err = arrow.TimestampFromTime(v.UTC(), arrow.Millisecond); err == nil {
+				b.Append(ts)
+			}
+		}
+	case *array.BinaryBuilder:
+		// Athena renders varbinary as space separated hex bytes.
+		var v []byte
+		if v, err = hex.DecodeString(strings.ReplaceAll(s, " ", "")); err == nil {
+			b.Append(v)
+		}
+	case *array.StringBuilder:
+		b.Append(s)
+	default:
+		return fmt.Errorf("unsupported Arrow builder %T", b)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot parse '%s' as %s: %w", s, col.Type, err)
+	}
+	return nil
+}
+
+// unloadRecordReader returns the record batches read from the Parquet files
+// a query's results were unloaded to.
+type unloadRecordReader struct {
+	recordReader
+	rows   *unloadRows
+	schema *arrow.Schema
+	record arrow.RecordBatch
+	err    error
+}
+
+func newUnloadRecordReader(r *unloadRows) *unloadRecordReader {
+	schema := r.schema
+	if schema == nil {
+		// There are no files, and so no columns, if there are no rows.
+		schema = arrow.NewSchema(nil, nil)
+	}
+
+	ur := &unloadRecordReader{rows: r, schema: schema}
+	ur.refs.Store(1)
+	ur.release = func() {
+		ur.releaseRecord()
+		if err := ur.rows.Close(); err != nil && ur.err == nil {
+			ur.err = err
+		}
+	}
+	return ur
+}
+
+func (r *unloadRecordReader) Schema() *arrow.Schema { return r.schema }
+
+func (r *unloadRecordReader) RecordBatch() arrow.RecordBatch { return r.record }
+
+// Deprecated: Use RecordBatch instead.
+func (r *unloadRecordReader) Record() arrow.RecordBatch { return r.record }
+
+// Err returns the error that stopped reading batches, if any. After the
+// reader's been released, it's also set if the files couldn't be deleted.
+func (r *unloadRecordReader) Err() error { return r.err }
+
+func (r *unloadRecordReader) releaseRecord() {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+}
+
+// Next returns the next batch read from the files, cut short if it holds more
+// rows than are left before MaxRows.
+func (r *unloadRecordReader) Next() bool {
+	r.releaseRecord()
+	if r.err != nil {
+		return false
+	}
+
+	var err error
+	if r.rows.rowsLeft() == 0 {
+		err = r.rows.rowLimitReached()
+	} else {
+		err = r.rows.nextRecord()
+	}
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			r.err = err
+		}
+		return false
+	}
+
+	// The batch is kept, past the reader moving on, until the next call.
+	record := r.rows.record
+	n := record.NumRows()
+	if left := r.rows.rowsLeft(); left >= 0 && n > left {
+		n = left
+		record = record.NewSlice(0, n)
+	} else {
+		record.Retain()
+	}
+	r.record = record
+	r.rows.row = int(n)
+	r.rows.read += int(n)
+	return true
+}
@@ -0,0 +1,300 @@
+package athena
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_QueryArrow(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"select": {QueryStateSucceeded},
+	}}
+	client := newMockClient(mock)
+
+	reader, err := client.QueryArrow(context.Background(), "select")
+	require.NoError(t, err)
+	defer reader.Release()
+
+	assert.Equal(t, "first_name", reader.Schema().Field(0).Name)
+	assert.Equal(t, arrow.BinaryTypes.String, reader.Schema().Field(1).Type)
+
+	// A batch per page, without the header row.
+	var sizes []int64
+	for reader.Next() {
+		sizes = append(sizes, reader.RecordBatch().NumRows())
+	}
+	require.NoError(t, reader.Err())
+	assert.Equal(t, []int64{4, 5}, sizes)
+}
+
+// csvAthenaClient reports its queries' results as written to CSV files.
+type csvAthenaClient struct {
+	*mockAsyncAthenaClient
+}
+
+func (m csvAthenaClient) GetQueryExecution(ctx context.Context, input *GetQueryExecutionInput) (*QueryExecution, error) {
+	execution, err := m.mockAsyncAthenaClient.GetQueryExecution(ctx, input)
+	if err == nil {
+		execution.OutputLocation = "s3://results/" + input.QueryID + ".csv"
+	}
+	return execution, err
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestClient_QueryArrow_CSV(t *testing.T) {
+	s3Client := &mockS3Client{}
+	s3Client.put("s3://results/select.csv", []byte(
+		"\"first_name\",\"last_name\"\n"+
+			"\"a\",\"b\"\n"+
+			",\"\"\n"+
+			"\"c, \"\"d\"\"\",\"e\nf\"\n"))
+	mock := csvAthenaClient{&mockAsyncAthenaClient{states: map[string][]string{
+		"select": {QueryStateSucceeded},
+	}}}
+	client := &Client{conn: &conn{
+		athena:         mock,
+		s3:             s3Client,
+		OutputLocation: "s3://results",
+		pollFrequency:  time.Millisecond,
+	}}
+
+	reader, err := client.QueryArrow(context.Background(), "select")
+	require.NoError(t, err)
+	defer reader.Release()
+
+	assert.Equal(t, "first_name", reader.Schema().Field(0).Name)
+	assert.Equal(t, arrow.BinaryTypes.String, reader.Schema().Field(1).Type)
+
+	require.True(t, reader.Next())
+	first := reader.RecordBatch().Column(0).(*array.String)
+	last := reader.RecordBatch().Column(1).(*array.String)
+	require.Equal(t, 3, first.Len())
+	assert.Equal(t, "a", first.Value(0))
+	assert.Equal(t, "b", last.Value(0))
+	assert.True(t, first.IsNull(1), "unquoted empty value isn't null")
+	assert.True(t, last.IsValid(1), "quoted empty value is null")
+	assert.Equal(t, "", last.Value(1))
+	assert.Equal(t, `c, "d"`, first.Value(2))
+	assert.Equal(t, "e\nf", last.Value(2))
+
+	assert.False(t, reader.Next())
+	require.NoError(t, reader.Err())
+}
+
+func TestClient_QueryArrow_CSV_MaxRows(t *testing.T) {
+	tests := []struct {
+		maxRows int64
+		stop    bool
+		rows    []int64
+		err     error
+	}{
+		{maxRows: 0, rows: []int64{3}},
+		{maxRows: 3, rows: []int64{3}},
+		{maxRows: 2, rows: []int64{2}, err: &ErrRowLimitExceeded{QueryID: "select", MaxRows: 2}},
+		{maxRows: 2, stop: true, rows: []int64{2}},
+	}
+	for _, test := range tests {
+		s3Client := &mockS3Client{}
+		s3Client.put("s3://results/select.csv", []byte(
+			"\"first_name\",\"last_name\"\n\"a\",\"b\"\n\"c\",\"d\"\n\"e\",\"f\"\n"))
+		var pages []PageInfo
+		var closed int
+		client := &Client{conn: &conn{
+			athena: csvAthenaClient{&mockAsyncAthenaClient{states: map[string][]string{
+				"select": {QueryStateSucceeded},
+			}}},
+			s3:             s3Client,
+			OutputLocation: "s3://results",
+			pollFrequency:  time.Millisecond,
+			stopAtMaxRows:  test.stop,
+			hooks: Hooks{
+				OnPage: func(_ context.Context, _ QueryInfo, page PageInfo) {
+					pages = append(pages, page)
+				},
+				OnClose: func(_ context.Context, _ QueryInfo, rows int) {
+					closed = rows
+				},
+			},
+		}}
+
+		reader, err := client.QueryArrow(WithMaxRows(context.Background(), test.maxRows), "select")
+		require.NoError(t, err)
+		var rows []int64
+		for reader.Next() {
+			rows = append(rows, reader.RecordBatch().NumRows())
+		}
+		assert.Equal(t, test.err, reader.Err(), "max rows %d", test.maxRows)
+		assert.Equal(t, test.rows, rows, "max rows %d", test.maxRows)
+		reader.Release()
+
+		require.Len(t, pages, 1, "max rows %d", test.maxRows)
+		assert.Equal(t, 1, pages[0].Number)
+		assert.Equal(t, int(test.rows[0]), pages[0].Rows)
+		assert.NoError(t, pages[0].Err)
+		assert.Equal(t, int(test.rows[0]), closed)
+	}
+}
+
+func TestReadCSVRecord(t *testing.T) {
+	tests := []struct {
+		csv      string
+		expected [][]*string
+	}{
+		{"", nil},
+		{"\"a\"", [][]*string{{stringPtr("a")}}},
+		{"\"a\",\"b\"\r\n\"c\",\r\n", [][]*string{{stringPtr("a"), stringPtr("b")}, {stringPtr("c"), nil}}},
+		{",\n\"\",\"\"\"\"\n", [][]*string{{nil, nil}, {stringPtr(""), stringPtr(`"`)}}},
+		{"\n", [][]*string{{nil}}},
+		{"1,2\n", [][]*string{{stringPtr("1"), stringPtr("2")}}},
+	}
+	for _, test := range tests {
+		r := bufio.NewReader(strings.NewReader(test.csv))
+		var records [][]*string
+		for {
+			record, err := readCSVRecord(r)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err, test.csv)
+			records = append(records, record)
+		}
+		assert.Equal(t, test.expected, records, test.csv)
+	}
+
+	for _, csv := range []string{"\"a", "\"a\"b\n"} {
+		_, err := readCSVRecord(bufio.NewReader(strings.NewReader(csv)))
+		assert.Error(t, err, csv)
+	}
+}
+
+func TestClient_QueryArrow_Unload(t *testing.T) {
+	s3Client := &mockS3Client{}
+	mock := &mockUnloadAthenaClient{
+		s3: s3Client,
+		files: [][]byte{
+			genParquet(t, `[{"id": 1, "name": "a", "price": "1.00", "tags": [], "created": "2024-01-02T00:00:00"}]`),
+			genParquet(t, `[{"id": 2, "name": "b", "price": "2.00", "tags": [], "created": "2024-01-02T00:00:00"}]`),
+		},
+	}
+	client := &Client{conn: &conn{
+		athena:         mock,
+		s3:             s3Client,
+		OutputLocation: "s3://results",
+		pollFrequency:  time.Millisecond,
+	}}
+
+	reader, err := client.QueryArrow(WithResultMode(context.Background(), ResultModeUnload), "SELECT * FROM t")
+	require.NoError(t, err)
+	for i, field := range unloadSchema.Fields() {
+		assert.Equal(t, field.Name, reader.Schema().Field(i).Name)
+		assert.True(t, arrow.TypeEqual(field.Type, reader.Schema().Field(i).Type), field.Name)
+	}
+
+	var ids []int32
+	for reader.Next() {
+		ids = append(ids, reader.RecordBatch().Column(0).(*array.Int32).Int32Values()...)
+	}
+	require.NoError(t, reader.Err())
+	assert.Equal(t, []int32{1, 2}, ids)
+
+	reader.Release()
+	assert.Empty(t, s3Client.objects, "results weren't cleaned up")
+}
+
+func TestAppendArrowValue(t *testing.T) {
+	tests := []struct {
+		athenaType string
+		value      string
+		expected   string
+	}{
+		{"tinyint", "-8", "-8"},
+		{"integer", "42", "42"},
+		{"bigint", "9007199254740993", "9007199254740993"},
+		{"double", "1.5", "1.5"},
+		{"decimal", "12.34", "12.34"},
+		{"boolean", "true", "true"},
+		{"date", "2024-01-02", "2024-01-02"},
+		{"timestamp", "2024-01-02 03:04:05.678", "2024-01-02T03:04:05.678"},
+		{"varbinary", "68 69", "aGk="},
+		{"array(integer)", "[1, 2]", "[1, 2]"},
+	}
+	for _, test := range tests {
+		col := &ColumnInfo{Type: test.athenaType, Precision: 4, Scale: 2}
+		b := array.NewBuilder(memory.DefaultAllocator, arrowType(col))
+		require.NoError(t, appendArrowValue(b, col, stringPtr(test.value)), test.athenaType)
+		require.NoError(t, appendArrowValue(b, col, nil), test.athenaType)
+
+		arr := b.NewArray()
+		assert.Equal(t, test.expected, arr.ValueStr(0), test.athenaType)
+		assert.True(t, arr.IsNull(1), test.athenaType)
+		arr.Release()
+		b.Release()
+	}
+
+	col := &ColumnInfo{Type: "integer"}
+	b := array.NewBuilder(memory.DefaultAllocator, arrowType(col))
+	defer b.Release()
+	assert.EqualError(t, appendArrowValue(b, col, stringPtr("x")), `cannot parse 'x' as integer: strconv.ParseInt: parsing "x": invalid syntax`)
+}
+
+func TestClient_QueryArrow_Unload_MaxRows(t *testing.T) {
+	s3Client := &mockS3Client{}
+	mock := &mockUnloadAthenaClient{
+		s3: s3Client,
+		files: [][]byte{
+			genParquet(t, `[
+				{"id": 1, "name": "a", "price": "1.00", "tags": [], "created": "2024-01-02T00:00:00"},
+				{"id": 2, "name": "b", "price": "2.00", "tags": [], "created": "2024-01-02T00:00:00"}
+			]`),
+			genParquet(t, `[{"id": 3, "name": "c", "price": "3.00", "tags": [], "created": "2024-01-02T00:00:00"}]`),
+		},
+	}
+	var closed int
+	client := &Client{conn: &conn{
+		athena:         mock,
+		s3:             s3Client,
+		OutputLocation: "s3://results",
+		pollFrequency:  time.Millisecond,
+		hooks: Hooks{OnClose: func(_ context.Context, _ QueryInfo, rows int) {
+			closed = rows
+		}},
+	}}
+	ctx := WithResultMode(context.Background(), ResultModeUnload)
+
+	// The batch that goes past the limit is cut short.
+	reader, err := client.QueryArrow(WithMaxRows(ctx, 1), "SELECT * FROM t")
+	require.NoError(t, err)
+	var ids []int32
+	for reader.Next() {
+		ids = append(ids, reader.RecordBatch().Column(0).(*array.Int32).Int32Values()...)
+	}
+	assert.Equal(t, &ErrRowLimitExceeded{QueryID: "unload", MaxRows: 1}, reader.Err())
+	assert.Equal(t, []int32{1}, ids)
+	reader.Release()
+	assert.Equal(t, 1, closed)
+
+	client.conn.stopAtMaxRows = true
+	reader, err = client.QueryArrow(WithMaxRows(ctx, 2), "SELECT * FROM t")
+	require.NoError(t, err)
+	ids = nil
+	for reader.Next() {
+		ids = append(ids, reader.RecordBatch().Column(0).(*array.Int32).Int32Values()...)
+	}
+	require.NoError(t, reader.Err())
+	assert.Equal(t, []int32{1, 2}, ids)
+	reader.Release()
+	assert.Equal(t, 2, closed)
+}
@@ -0,0 +1,213 @@
+// Package athenaotel traces go-athena queries with OpenTelemetry.
+//
+// Each query gets a span, from when it's submitted until its results are
+// closed, with events for each state Athena reports (queued, running, ...)
+// and the number of rows read. Within it, the calls made to Athena and the
+// other hooks see it as the current span, and every GetQueryResults call gets
+// a span of its own, as its child.
+//
+//	cfg := athenaotel.Wrap(athena.Config{...})
+//	db, err := athena.Open(cfg)
+//
+// Queries started with Client.StartQuery() or RunBatch() aren't waited on
+// with the context they're started with, so their span is only recorded once
+// they complete, as a child of the span in the context they're waited on with,
+// and the pages of their results get standalone spans.
+package athenaotel
+
+import (
+	"context"
+	"strings"
+
+	athena "github.com/segmentio/go-athena"
+	"github.com/segmentio/go-athena/presto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/segmentio/go-athena/athenaotel"
+
+// Attribute keys set on spans, on top of the db.* ones.
+const (
+	QueryIDKey          = attribute.Key("athena.query_id")
+	WorkGroupKey        = attribute.Key("athena.workgroup")
+	StatementTypeKey    = attribute.Key("athena.statement_type")
+	DataScannedBytesKey = attribute.Key("athena.data_scanned_bytes")
+	ResultReusedKey     = attribute.Key("athena.result_reused")
+	PageNumberKey       = attribute.Key("athena.page_number")
+	PageRowsKey         = attribute.Key("athena.page_rows")
+	RowsReturnedKey     = attribute.Key("athena.rows_returned")
+)
+
+type statementMode int
+
+const (
+	noStatement statementMode = iota
+	redactedStatement
+	rawStatement
+)
+
+// Option configures Wrap().
+type Option func(*tracer)
+
+// WithTracerProvider sets the TracerProvider spans are created with.
+// It defaults to the global one.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(t *tracer) {
+		t.provider = provider
+	}
+}
+
+// WithStatement records each query's SQL on its span, with every literal
+// replaced by '?' and comments removed.
+func WithStatement() Option {
+	return func(t *tracer) {
+		t.statement = redactedStatement
+	}
+}
+
+// WithRawStatement records each query's SQL on its span as is.
+// Beware that it may contain sensitive values.
+func WithRawStatement() Option {
+	return func(t *tracer) {
+		t.statement = rawStatement
+	}
+}
+
+// Wrap returns a copy of cfg whose Hooks also trace queries.
+// See athena.WithHooks() about setting hooks per query.
+func Wrap(cfg athena.Config, opts ...Option) athena.Config {
+	cfg.Hooks = athena.ComposeHooks(cfg.Hooks, Hooks(opts...))
+	return cfg
+}
+
+// Hooks returns the Hooks used by Wrap().
+func Hooks(opts ...Option) athena.Hooks {
+	t := tracer{provider: otel.GetTracerProvider()}
+	for _, opt := range opts {
+		opt(&t)
+	}
+	t.tracer = t.provider.Tracer(instrumentationName)
+
+	return athena.Hooks{
+		OnSubmit:      t.onSubmit,
+		OnStart:       t.onStart,
+		OnStateChange: t.onStateChange,
+		OnComplete:    t.onComplete,
+		OnPage:        t.onPage,
+		OnClose:       t.onClose,
+	}
+}
+
+type tracer struct {
+	provider  trace.TracerProvider
+	tracer    trace.Tracer
+	statement statementMode
+}
+
+// querySpanKey is the context key of the span of the query being run with a
+// context.
+type querySpanKey struct{}
+
+func querySpan(ctx context.Context) trace.Span {
+	span, _ := ctx.Value(querySpanKey{}).(trace.Span)
+	return span
+}
+
+func (t *tracer) startQuerySpan(ctx context.Context, query string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{attribute.String("db.system", "athena")}
+	switch t.statement {
+	case redactedStatement:
+		attrs = append(attrs, attribute.String("db.statement", presto.Redact(query)))
+	case rawStatement:
+		attrs = append(attrs, attribute.String("db.statement", query))
+	}
+
+	opts = append(opts, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return t.tracer.Start(ctx, "athena.query", opts...)
+}
+
+func (t *tracer) onSubmit(ctx context.Context, query string) context.Context {
+	ctx, span := t.startQuerySpan(ctx, query)
+	return context.WithValue(ctx, querySpanKey{}, span)
+}
+
+func (t *tracer) onStart(ctx context.Context, info athena.QueryInfo) {
+	if span := querySpan(ctx); span != nil {
+		span.SetAttributes(
+			attribute.String("db.name", info.Database),
+			QueryIDKey.String(info.QueryID),
+			WorkGroupKey.String(info.WorkGroup),
+		)
+	}
+}
+
+func (t *tracer) onStateChange(ctx context.Context, _ athena.QueryInfo, _, to string) {
+	if span := querySpan(ctx); span != nil {
+		span.AddEvent(strings.ToLower(to))
+	}
+}
+
+func (t *tracer) onComplete(ctx context.Context, info athena.QueryInfo, status athena.QueryStatus, err error) {
+	span := querySpan(ctx)
+	retroactive := span == nil
+	var endOpts []trace.SpanEndOption
+	if retroactive {
+		// The query wasn't submitted with ctx, so its span is made now.
+		startedAt := info.SubmittedAt
+		if startedAt.IsZero() {
+			startedAt = status.SubmittedAt
+		}
+		var startOpts []trace.SpanStartOption
+		if !startedAt.IsZero() {
+			startOpts = append(startOpts, trace.WithTimestamp(startedAt))
+		}
+		_, span = t.startQuerySpan(ctx, info.Query, startOpts...)
+		if !status.CompletedAt.IsZero() {
+			endOpts = append(endOpts, trace.WithTimestamp(status.CompletedAt))
+		}
+	}
+
+	span.SetAttributes(
+		attribute.String("db.name", info.Database),
+		QueryIDKey.String(info.QueryID),
+		WorkGroupKey.String(info.WorkGroup),
+		StatementTypeKey.String(info.StatementType),
+		DataScannedBytesKey.Int64(status.Stats.DataScannedInBytes),
+		ResultReusedKey.Bool(status.Stats.ResultReused),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	// Submitted queries that succeeded end once their results are closed.
+	if retroactive || err != nil {
+		span.End(endOpts...)
+	}
+}
+
+func (t *tracer) onClose(ctx context.Context, _ athena.QueryInfo, rows int) {
+	if span := querySpan(ctx); span != nil {
+		span.SetAttributes(RowsReturnedKey.Int(rows))
+		span.End()
+	}
+}
+
+func (t *tracer) onPage(ctx context.Context, info athena.QueryInfo, page athena.PageInfo) {
+	_, span := t.tracer.Start(ctx, "athena.GetQueryResults",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(page.StartedAt),
+		trace.WithAttributes(
+			QueryIDKey.String(info.QueryID),
+			PageNumberKey.Int(page.Number),
+			PageRowsKey.Int(page.Rows),
+		),
+	)
+	if page.Err != nil {
+		span.RecordError(page.Err)
+		span.SetStatus(codes.Error, page.Err.Error())
+	}
+	span.End(trace.WithTimestamp(page.StartedAt.Add(page.Duration)))
+}
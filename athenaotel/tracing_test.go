@@ -0,0 +1,150 @@
+package athenaotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	athena "github.com/segmentio/go-athena"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newRecorder() (*tracetest.SpanRecorder, Option) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return recorder, WithTracerProvider(provider)
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestHooks(t *testing.T) {
+	recorder, provider := newRecorder()
+	cfg := Wrap(athena.Config{}, provider, WithStatement())
+	hooks := cfg.Hooks
+
+	parentCtx, parent := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "parent")
+	query := "SELECT * FROM t WHERE secret = 'hunter2'"
+	ctx := hooks.OnSubmit(parentCtx, query)
+	require.NotNil(t, ctx)
+	assert.NotEqual(t, parent.SpanContext().SpanID(), trace.SpanContextFromContext(ctx).SpanID(), "query span should be current")
+
+	info := athena.QueryInfo{
+		QueryID:     "id",
+		Query:       query,
+		Database:    "db",
+		WorkGroup:   "primary",
+		SubmittedAt: time.Now(),
+	}
+	hooks.OnStart(ctx, info)
+	hooks.OnStateChange(ctx, info, "", "QUEUED")
+	hooks.OnStateChange(ctx, info, "QUEUED", "RUNNING")
+	hooks.OnStateChange(ctx, info, "RUNNING", "SUCCEEDED")
+
+	info.StatementType = "DML"
+	hooks.OnComplete(ctx, info, athena.QueryStatus{
+		State: "SUCCEEDED",
+		Stats: athena.QueryStats{DataScannedInBytes: 1024},
+	}, nil)
+
+	spans := recorder.Ended()
+	require.Empty(t, spans, "query span shouldn't end before its results are closed")
+
+	// Results are read with the context OnSubmit returned.
+	hooks.OnPage(ctx, info, athena.PageInfo{Number: 1, Rows: 999, StartedAt: time.Now(), Duration: time.Millisecond})
+	hooks.OnPage(ctx, info, athena.PageInfo{Number: 2, Rows: 1, StartedAt: time.Now(), Duration: time.Millisecond})
+	hooks.OnClose(ctx, info, 1000)
+
+	spans = recorder.Ended()
+	require.Len(t, spans, 3)
+	span := spans[2]
+	assert.Equal(t, "athena.query", span.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	for _, page := range spans[:2] {
+		assert.Equal(t, "athena.GetQueryResults", page.Name())
+		assert.Equal(t, span.SpanContext().SpanID(), page.Parent().SpanID())
+		assert.Equal(t, "id", attributes(page)[QueryIDKey].AsString())
+	}
+
+	attrs := attributes(span)
+	assert.Equal(t, "id", attrs[QueryIDKey].AsString())
+	assert.Equal(t, "db", attrs["db.name"].AsString())
+	assert.Equal(t, "primary", attrs[WorkGroupKey].AsString())
+	assert.Equal(t, "DML", attrs[StatementTypeKey].AsString())
+	assert.Equal(t, int64(1024), attrs[DataScannedBytesKey].AsInt64())
+	assert.Equal(t, int64(1000), attrs[RowsReturnedKey].AsInt64())
+	assert.Equal(t, "SELECT * FROM t WHERE secret = ?", attrs["db.statement"].AsString())
+
+	var events []string
+	for _, event := range span.Events() {
+		events = append(events, event.Name)
+	}
+	assert.Equal(t, []string{"queued", "running", "succeeded"}, events)
+}
+
+func TestHooks_Failure(t *testing.T) {
+	recorder, provider := newRecorder()
+	hooks := Hooks(provider)
+
+	info := athena.QueryInfo{QueryID: "id", Query: "SELECT 'secret'", SubmittedAt: time.Now()}
+	ctx := hooks.OnSubmit(context.Background(), info.Query)
+	hooks.OnStart(ctx, info)
+	hooks.OnComplete(ctx, info, athena.QueryStatus{State: "FAILED"}, errors.New("boom"))
+
+	// Queries that fail have no results to close.
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "boom", spans[0].Status().Description)
+	_, ok := attributes(spans[0])["db.statement"]
+	assert.False(t, ok, "SQL shouldn't be recorded by default")
+}
+
+func TestHooks_NotSubmitted(t *testing.T) {
+	recorder, provider := newRecorder()
+	hooks := Hooks(provider)
+	ctx := context.Background()
+
+	// As with Client.StartQuery(), waited on later with another context.
+	submittedAt := time.Now().Add(-time.Minute)
+	info := athena.QueryInfo{QueryID: "id", Query: "SELECT 1", SubmittedAt: submittedAt}
+	hooks.OnStart(ctx, info)
+	hooks.OnStateChange(ctx, info, "", "RUNNING")
+	assert.Empty(t, recorder.Started())
+
+	completedAt := time.Now()
+	hooks.OnComplete(ctx, info, athena.QueryStatus{State: "SUCCEEDED", CompletedAt: completedAt}, nil)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "athena.query", spans[0].Name())
+	assert.Equal(t, "id", attributes(spans[0])[QueryIDKey].AsString())
+	assert.True(t, spans[0].StartTime().Equal(submittedAt))
+	assert.True(t, spans[0].EndTime().Equal(completedAt))
+
+	// Their results' pages get spans of their own.
+	hooks.OnPage(ctx, info, athena.PageInfo{Number: 1, Rows: 1, StartedAt: time.Now()})
+	hooks.OnClose(ctx, info, 1)
+	spans = recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "athena.GetQueryResults", spans[1].Name())
+	assert.False(t, spans[1].Parent().IsValid())
+
+	// Queries that couldn't be started have no submission time.
+	hooks.OnComplete(ctx, athena.QueryInfo{Query: "SELECT 1"}, athena.QueryStatus{}, errors.New("throttled"))
+	spans = recorder.Ended()
+	require.Len(t, spans, 3)
+	assert.WithinDuration(t, time.Now(), spans[2].StartTime(), time.Minute)
+}
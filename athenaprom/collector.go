@@ -0,0 +1,190 @@
+// Package athenaprom exports Prometheus metrics about go-athena queries.
+//
+//	collector := athenaprom.NewCollector()
+//	prometheus.MustRegister(collector)
+//	db, err := athena.Open(collector.Wrap(athena.Config{...}))
+//
+// Every metric is labelled with the query's database and workgroup.
+package athenaprom
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	athena "github.com/segmentio/go-athena"
+)
+
+const namespace = "athena"
+
+var labels = []string{"database", "workgroup"}
+
+// Failure categories for queries that failed without Athena reporting why.
+const (
+	CategoryCancelled  = "cancelled"
+	CategoryScanBudget = "scan_budget"
+	CategoryThrottled  = "throttled"
+	CategoryDriver     = "driver"
+)
+
+// Collector is a prometheus.Collector fed by the driver's Hooks.
+type Collector struct {
+	queries     *prometheus.CounterVec
+	failures    *prometheus.CounterVec
+	queueTime   *prometheus.HistogramVec
+	runTime     *prometheus.HistogramVec
+	totalTime   *prometheus.HistogramVec
+	dataScanned *prometheus.CounterVec
+	polls       *prometheus.CounterVec
+	pollErrors  *prometheus.CounterVec
+	pages       *prometheus.CounterVec
+	rows        *prometheus.CounterVec
+}
+
+// NewCollector returns a Collector. It must be registered, e.g. with
+// prometheus.MustRegister(), and installed with Wrap() or Hooks().
+func NewCollector() *Collector {
+	buckets := prometheus.ExponentialBuckets(0.1, 2, 14)
+	return &Collector{
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "queries_total",
+			Help:      "Queries the driver stopped waiting on, whether they succeeded or not, including those it couldn't start.",
+		}, labels),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "query_failures_total",
+			Help:      "Queries that failed, by Athena error category (system, user, other) or cancelled, scan_budget, throttled and driver.",
+		}, append(labels, "category")),
+		queueTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "query_queue_seconds",
+			Help:      "Time queries spent queued in Athena.",
+			Buckets:   buckets,
+		}, labels),
+		runTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "query_run_seconds",
+			Help:      "Time queries spent running in Athena's engine.",
+			Buckets:   buckets,
+		}, labels),
+		totalTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "query_duration_seconds",
+			Help:      "Total time Athena took to execute queries.",
+			Buckets:   buckets,
+		}, labels),
+		dataScanned: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "data_scanned_bytes_total",
+			Help:      "Bytes scanned by queries.",
+		}, labels),
+		polls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "query_polls_total",
+			Help:      "Query statuses fetched while waiting on queries.",
+		}, labels),
+		pollErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "query_poll_errors_total",
+			Help:      "Failures to fetch the status of queries while waiting on them.",
+		}, append(labels, "category")),
+		pages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "result_pages_total",
+			Help:      "Pages of results fetched with GetQueryResults.",
+		}, labels),
+		rows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "result_rows_total",
+			Help:      "Rows of results fetched with GetQueryResults.",
+		}, labels),
+	}
+}
+
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.queries, c.failures,
+		c.queueTime, c.runTime, c.totalTime,
+		c.dataScanned, c.polls, c.pollErrors, c.pages, c.rows,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+// Wrap returns a copy of cfg whose Hooks also feed the collector.
+// See athena.WithHooks() about setting hooks per query.
+func (c *Collector) Wrap(cfg athena.Config) athena.Config {
+	cfg.Hooks = athena.ComposeHooks(cfg.Hooks, c.Hooks())
+	return cfg
+}
+
+// Hooks returns the Hooks that feed the collector.
+func (c *Collector) Hooks() athena.Hooks {
+	return athena.Hooks{
+		OnPoll:      c.onPoll,
+		OnPollError: c.onPollError,
+		OnComplete:  c.onComplete,
+		OnPage:      c.onPage,
+	}
+}
+
+func (c *Collector) onPoll(_ context.Context, info athena.QueryInfo, _ athena.QueryStatus) {
+	c.polls.WithLabelValues(info.Database, info.WorkGroup).Inc()
+}
+
+func (c *Collector) onPollError(_ context.Context, info athena.QueryInfo, err error) {
+	c.pollErrors.WithLabelValues(info.Database, info.WorkGroup, failureCategory(athena.QueryStatus{}, err)).Inc()
+}
+
+func (c *Collector) onComplete(_ context.Context, info athena.QueryInfo, status athena.QueryStatus, err error) {
+	c.queries.WithLabelValues(info.Database, info.WorkGroup).Inc()
+	if err != nil {
+		c.failures.WithLabelValues(info.Database, info.WorkGroup, failureCategory(status, err)).Inc()
+	}
+	if info.Joined {
+		// The caller that started the query reports what it cost.
+		return
+	}
+
+	stats := status.Stats
+	c.dataScanned.WithLabelValues(info.Database, info.WorkGroup).Add(float64(stats.DataScannedInBytes))
+	if status.Done() {
+		c.queueTime.WithLabelValues(info.Database, info.WorkGroup).Observe(stats.QueueTime.Seconds())
+		c.runTime.WithLabelValues(info.Database, info.WorkGroup).Observe(stats.EngineExecutionTime.Seconds())
+		c.totalTime.WithLabelValues(info.Database, info.WorkGroup).Observe(stats.TotalExecutionTime.Seconds())
+	}
+}
+
+func (c *Collector) onPage(_ context.Context, info athena.QueryInfo, page athena.PageInfo) {
+	c.pages.WithLabelValues(info.Database, info.WorkGroup).Inc()
+	c.rows.WithLabelValues(info.Database, info.WorkGroup).Add(float64(page.Rows))
+}
+
+func failureCategory(status athena.QueryStatus, err error) string {
+	var budgetErr *athena.ErrScanBudgetExceeded
+	switch {
+	case status.ErrorCategory != "":
+		return status.ErrorCategory
+	case errors.As(err, &budgetErr):
+		return CategoryScanBudget
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return CategoryCancelled
+	case athena.IsThrottlingError(err):
+		return CategoryThrottled
+	default:
+		return CategoryDriver
+	}
+}
@@ -0,0 +1,130 @@
+package athenaprom
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	athena "github.com/segmentio/go-athena"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+	hooks := c.Wrap(athena.Config{}).Hooks
+	ctx := context.Background()
+	info := athena.QueryInfo{QueryID: "id", Database: "db", WorkGroup: "primary"}
+
+	hooks.OnPoll(ctx, info, athena.QueryStatus{State: "RUNNING"})
+	hooks.OnPoll(ctx, info, athena.QueryStatus{State: "SUCCEEDED"})
+	hooks.OnComplete(ctx, info, athena.QueryStatus{
+		State: "SUCCEEDED",
+		Stats: athena.QueryStats{
+			DataScannedInBytes:  1024,
+			QueueTime:           time.Second,
+			EngineExecutionTime: 2 * time.Second,
+			TotalExecutionTime:  3 * time.Second,
+		},
+	}, nil)
+	hooks.OnPage(ctx, info, athena.PageInfo{Number: 1, Rows: 1000})
+	hooks.OnPage(ctx, info, athena.PageInfo{Number: 2, Rows: 10})
+
+	// Queries that joined another's execution don't count its cost again.
+	joined := info
+	joined.Joined = true
+	hooks.OnComplete(ctx, joined, athena.QueryStatus{
+		State: "SUCCEEDED",
+		Stats: athena.QueryStats{DataScannedInBytes: 1024, TotalExecutionTime: 3 * time.Second},
+	}, nil)
+	hooks.OnPollError(ctx, info, errors.New("connection reset"))
+
+	hooks.OnComplete(ctx, info, athena.QueryStatus{State: "FAILED", ErrorCategory: athena.ErrorCategoryUser}, errors.New("syntax error"))
+	hooks.OnComplete(ctx, info, athena.QueryStatus{State: "RUNNING"}, context.Canceled)
+	hooks.OnComplete(ctx, info, athena.QueryStatus{State: "RUNNING"}, &athena.ErrScanBudgetExceeded{})
+
+	expected := `
+# HELP athena_data_scanned_bytes_total Bytes scanned by queries.
+# TYPE athena_data_scanned_bytes_total counter
+athena_data_scanned_bytes_total{database="db",workgroup="primary"} 1024
+# HELP athena_queries_total Queries the driver stopped waiting on, whether they succeeded or not, including those it couldn't start.
+# TYPE athena_queries_total counter
+athena_queries_total{database="db",workgroup="primary"} 5
+# HELP athena_query_failures_total Queries that failed, by Athena error category (system, user, other) or cancelled, scan_budget, throttled and driver.
+# TYPE athena_query_failures_total counter
+athena_query_failures_total{category="cancelled",database="db",workgroup="primary"} 1
+athena_query_failures_total{category="scan_budget",database="db",workgroup="primary"} 1
+athena_query_failures_total{category="user",database="db",workgroup="primary"} 1
+# HELP athena_query_poll_errors_total Failures to fetch the status of queries while waiting on them.
+# TYPE athena_query_poll_errors_total counter
+athena_query_poll_errors_total{category="driver",database="db",workgroup="primary"} 1
+# HELP athena_query_polls_total Query statuses fetched while waiting on queries.
+# TYPE athena_query_polls_total counter
+athena_query_polls_total{database="db",workgroup="primary"} 2
+# HELP athena_result_pages_total Pages of results fetched with GetQueryResults.
+# TYPE athena_result_pages_total counter
+athena_result_pages_total{database="db",workgroup="primary"} 2
+# HELP athena_result_rows_total Rows of results fetched with GetQueryResults.
+# TYPE athena_result_rows_total counter
+athena_result_rows_total{database="db",workgroup="primary"} 1010
+`
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"athena_data_scanned_bytes_total",
+		"athena_queries_total",
+		"athena_query_failures_total",
+		"athena_query_poll_errors_total",
+		"athena_query_polls_total",
+		"athena_result_pages_total",
+		"athena_result_rows_total",
+	))
+
+	// Only the two finished queries are observed.
+	assert.Equal(t, 1, testutil.CollectAndCount(c, "athena_query_queue_seconds"))
+}
+
+// throttledAPI throttles every query it's asked to start.
+type throttledAPI struct {
+	athena.AthenaAPI
+}
+
+func (throttledAPI) StartQueryExecution(context.Context, *athena.StartQueryExecutionInput) (*athena.StartQueryExecutionOutput, error) {
+	return nil, awserr.New("ThrottlingException", "Rate exceeded", nil)
+}
+
+func TestCollector_StartFailures(t *testing.T) {
+	c := NewCollector()
+	cfg := c.Wrap(athena.Config{
+		API:            throttledAPI{},
+		Database:       "db",
+		OutputLocation: "s3://results",
+	})
+	ctx := context.Background()
+
+	db, err := athena.Open(cfg)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.QueryContext(ctx, "SELECT 1")
+	assert.Error(t, err)
+
+	client, err := athena.NewClient(cfg)
+	require.NoError(t, err)
+	_, err = client.StartQuery(ctx, "SELECT 1")
+	assert.Error(t, err)
+
+	expected := `
+# HELP athena_queries_total Queries the driver stopped waiting on, whether they succeeded or not, including those it couldn't start.
+# TYPE athena_queries_total counter
+athena_queries_total{database="db",workgroup=""} 2
+# HELP athena_query_failures_total Queries that failed, by Athena error category (system, user, other) or cancelled, scan_budget, throttled and driver.
+# TYPE athena_query_failures_total counter
+athena_query_failures_total{category="throttled",database="db",workgroup=""} 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"athena_queries_total",
+		"athena_query_failures_total",
+	))
+}
@@ -0,0 +1,255 @@
+// Package athenav2 lets go-athena call Athena with the AWS SDK for Go v2.
+//
+//	awsCfg, err := config.LoadDefaultConfig(ctx)
+//	db, err := athena.Open(athena.Config{
+//		API:            athenav2.New(awsCfg),
+//		Database:       "default",
+//		OutputLocation: "s3://results",
+//	})
+//
+// Queries run with goathena.ResultModeUnload also need an S3 client, which
+// NewS3() makes the same way:
+//
+//	db, err := athena.Open(athena.Config{
+//		API:            athenav2.New(awsCfg),
+//		S3:             athenav2.NewS3(awsCfg),
+//		...
+//	})
+//
+// Errors returned by AWS are converted to the SDK v1's awserr.Error, so that
+// the driver recognizes throttling.
+package athenav2
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/smithy-go"
+	goathena "github.com/segmentio/go-athena"
+)
+
+// Client is the part of *athena.Client the driver calls.
+type Client interface {
+	StartQueryExecution(ctx context.Context, input *athena.StartQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.StartQueryExecutionOutput, error)
+	StopQueryExecution(ctx context.Context, input *athena.StopQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.StopQueryExecutionOutput, error)
+	GetQueryExecution(ctx context.Context, input *athena.GetQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.GetQueryExecutionOutput, error)
+	BatchGetQueryExecution(ctx context.Context, input *athena.BatchGetQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.BatchGetQueryExecutionOutput, error)
+	GetQueryResults(ctx context.Context, input *athena.GetQueryResultsInput, optFns ...func(*athena.Options)) (*athena.GetQueryResultsOutput, error)
+}
+
+var _ Client = (*athena.Client)(nil)
+
+// New returns a goathena.AthenaAPI that calls Athena with a client for cfg.
+func New(cfg aws.Config, optFns ...func(*athena.Options)) goathena.AthenaAPI {
+	return NewFromClient(athena.NewFromConfig(cfg, optFns...))
+}
+
+// NewFromClient returns a goathena.AthenaAPI that calls Athena with client.
+func NewFromClient(client Client) goathena.AthenaAPI {
+	return api{client: client}
+}
+
+type api struct {
+	client Client
+}
+
+func (a api) StartQueryExecution(ctx context.Context, input *goathena.StartQueryExecutionInput) (*goathena.StartQueryExecutionOutput, error) {
+	in := &athena.StartQueryExecutionInput{
+		QueryString: aws.String(input.Query),
+		QueryExecutionContext: &types.QueryExecutionContext{
+			Database: aws.String(input.Database),
+		},
+		ResultConfiguration: &types.ResultConfiguration{
+			OutputLocation: aws.String(input.OutputLocation),
+		},
+	}
+	if input.Catalog != "" {
+		in.QueryExecutionContext.Catalog = aws.String(input.Catalog)
+	}
+	if input.WorkGroup != "" {
+		in.WorkGroup = aws.String(input.WorkGroup)
+	}
+	if input.ExpectedBucketOwner != "" {
+		in.ResultConfiguration.ExpectedBucketOwner = aws.String(input.ExpectedBucketOwner)
+	}
+	if input.S3ACLOption != "" {
+		in.ResultConfiguration.AclConfiguration = &types.AclConfiguration{
+			S3AclOption: types.S3AclOption(input.S3ACLOption),
+		}
+	}
+	if enc := input.Encryption; enc != nil {
+		in.ResultConfiguration.EncryptionConfiguration = &types.EncryptionConfiguration{
+			EncryptionOption: types.EncryptionOption(enc.Option),
+		}
+		if enc.KMSKey != "" {
+			in.ResultConfiguration.EncryptionConfiguration.KmsKey = aws.String(enc.KMSKey)
+		}
+	}
+	if input.ClientRequestToken != "" {
+		in.ClientRequestToken = aws.String(input.ClientRequestToken)
+	}
+	if input.ResultReuseMaxAge > 0 {
+		in.ResultReuseConfiguration = &types.ResultReuseConfiguration{
+			ResultReuseByAgeConfiguration: &types.ResultReuseByAgeConfiguration{
+				Enabled:         true,
+				MaxAgeInMinutes: aws.Int32(int32(input.ResultReuseMaxAge / time.Minute)),
+			},
+		}
+	}
+
+	out, err := a.client.StartQueryExecution(ctx, in)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return &goathena.StartQueryExecutionOutput{QueryID: aws.ToString(out.QueryExecutionId)}, nil
+}
+
+func (a api) StopQueryExecution(ctx context.Context, input *goathena.StopQueryExecutionInput) error {
+	_, err := a.client.StopQueryExecution(ctx, &athena.StopQueryExecutionInput{
+		QueryExecutionId: aws.String(input.QueryID),
+	})
+	return convertError(err)
+}
+
+func (a api) GetQueryExecution(ctx context.Context, input *goathena.GetQueryExecutionInput) (*goathena.QueryExecution, error) {
+	out, err := a.client.GetQueryExecution(ctx, &athena.GetQueryExecutionInput{
+		QueryExecutionId: aws.String(input.QueryID),
+	})
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return convertQueryExecution(out.QueryExecution), nil
+}
+
+func (a api) BatchGetQueryExecution(ctx context.Context, input *goathena.BatchGetQueryExecutionInput) (*goathena.BatchGetQueryExecutionOutput, error) {
+	out, err := a.client.BatchGetQueryExecution(ctx, &athena.BatchGetQueryExecutionInput{
+		QueryExecutionIds: input.QueryIDs,
+	})
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	var result goathena.BatchGetQueryExecutionOutput
+	for i := range out.QueryExecutions {
+		result.QueryExecutions = append(result.QueryExecutions, convertQueryExecution(&out.QueryExecutions[i]))
+	}
+	for _, unprocessed := range out.UnprocessedQueryExecutionIds {
+		result.Unprocessed = append(result.Unprocessed, goathena.UnprocessedQueryExecution{
+			QueryID:      aws.ToString(unprocessed.QueryExecutionId),
+			ErrorCode:    aws.ToString(unprocessed.ErrorCode),
+			ErrorMessage: aws.ToString(unprocessed.ErrorMessage),
+		})
+	}
+	return &result, nil
+}
+
+func (a api) GetQueryResults(ctx context.Context, input *goathena.GetQueryResultsInput) (*goathena.GetQueryResultsOutput, error) {
+	in := &athena.GetQueryResultsInput{
+		QueryExecutionId: aws.String(input.QueryID),
+	}
+	if input.NextToken != "" {
+		in.NextToken = aws.String(input.NextToken)
+	}
+	if input.MaxResults > 0 {
+		in.MaxResults = aws.Int32(int32(input.MaxResults))
+	}
+
+	out, err := a.client.GetQueryResults(ctx, in)
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	result := &goathena.GetQueryResultsOutput{NextToken: aws.ToString(out.NextToken)}
+	if rs := out.ResultSet; rs != nil {
+		if rs.ResultSetMetadata != nil {
+			for _, col := range rs.ResultSetMetadata.ColumnInfo {
+				result.Columns = append(result.Columns, goathena.ColumnInfo{
+					Name:        aws.ToString(col.Name),
+					Type:        aws.ToString(col.Type),
+					CatalogName: aws.ToString(col.CatalogName),
+					SchemaName:  aws.ToString(col.SchemaName),
+					TableName:   aws.ToString(col.TableName),
+					Precision:   int64(col.Precision),
+					Scale:       int64(col.Scale),
+				})
+			}
+		}
+		for _, row := range rs.Rows {
+			values := make([]*string, len(row.Data))
+			for i, datum := range row.Data {
+				values[i] = datum.VarCharValue
+			}
+			result.Rows = append(result.Rows, values)
+		}
+	}
+	return result, nil
+}
+
+func convertQueryExecution(qe *types.QueryExecution) *goathena.QueryExecution {
+	if qe == nil {
+		return nil
+	}
+
+	result := &goathena.QueryExecution{
+		QueryID:       aws.ToString(qe.QueryExecutionId),
+		Query:         aws.ToString(qe.Query),
+		StatementType: string(qe.StatementType),
+		WorkGroup:     aws.ToString(qe.WorkGroup),
+	}
+	if qec := qe.QueryExecutionContext; qec != nil {
+		result.Database = aws.ToString(qec.Database)
+	}
+	if rc := qe.ResultConfiguration; rc != nil {
+		result.OutputLocation = aws.ToString(rc.OutputLocation)
+	}
+	if status := qe.Status; status != nil {
+		result.Status = goathena.QueryExecutionStatus{
+			State:              string(status.State),
+			StateChangeReason:  aws.ToString(status.StateChangeReason),
+			SubmissionDateTime: aws.ToTime(status.SubmissionDateTime),
+			CompletionDateTime: aws.ToTime(status.CompletionDateTime),
+		}
+		if athenaErr := status.AthenaError; athenaErr != nil {
+			result.Status.AthenaError = &goathena.AthenaError{
+				ErrorCategory: int64(aws.ToInt32(athenaErr.ErrorCategory)),
+				ErrorType:     int64(aws.ToInt32(athenaErr.ErrorType)),
+				ErrorMessage:  aws.ToString(athenaErr.ErrorMessage),
+				Retryable:     athenaErr.Retryable,
+			}
+		}
+	}
+	if stats := qe.Statistics; stats != nil {
+		result.Statistics = goathena.QueryExecutionStatistics{
+			DataScannedInBytes:    aws.ToInt64(stats.DataScannedInBytes),
+			QueryQueueTime:        millis(stats.QueryQueueTimeInMillis),
+			QueryPlanningTime:     millis(stats.QueryPlanningTimeInMillis),
+			EngineExecutionTime:   millis(stats.EngineExecutionTimeInMillis),
+			ServiceProcessingTime: millis(stats.ServiceProcessingTimeInMillis),
+			TotalExecutionTime:    millis(stats.TotalExecutionTimeInMillis),
+			DataManifestLocation:  aws.ToString(stats.DataManifestLocation),
+		}
+		if reuse := stats.ResultReuseInformation; reuse != nil {
+			result.Statistics.ResultReused = reuse.ReusedPreviousResult
+		}
+	}
+	return result
+}
+
+// convertError converts errors returned by Athena to awserr.Errors, keeping
+// their code and message.
+func convertError(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return awserr.New(apiErr.ErrorCode(), apiErr.ErrorMessage(), err)
+	}
+	return err
+}
+
+func millis(ms *int64) time.Duration {
+	return time.Duration(aws.ToInt64(ms)) * time.Millisecond
+}
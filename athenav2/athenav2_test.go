@@ -0,0 +1,202 @@
+package athenav2
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/smithy-go"
+	goathena "github.com/segmentio/go-athena"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClient struct {
+	Client
+	started *athena.StartQueryExecutionInput
+	err     error
+}
+
+func (c *fakeClient) StartQueryExecution(_ context.Context, input *athena.StartQueryExecutionInput, _ ...func(*athena.Options)) (*athena.StartQueryExecutionOutput, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.started = input
+	return &athena.StartQueryExecutionOutput{QueryExecutionId: aws.String("id")}, nil
+}
+
+func (c *fakeClient) GetQueryExecution(_ context.Context, input *athena.GetQueryExecutionInput, _ ...func(*athena.Options)) (*athena.GetQueryExecutionOutput, error) {
+	return &athena.GetQueryExecutionOutput{QueryExecution: &types.QueryExecution{
+		QueryExecutionId: input.QueryExecutionId,
+		StatementType:    types.StatementTypeDml,
+		Status: &types.QueryExecutionStatus{
+			State:              types.QueryExecutionStateFailed,
+			StateChangeReason:  aws.String("syntax error"),
+			CompletionDateTime: aws.Time(time.Unix(0, 0)),
+			AthenaError: &types.AthenaError{
+				ErrorCategory: aws.Int32(2),
+				ErrorType:     aws.Int32(1000),
+			},
+		},
+		Statistics: &types.QueryExecutionStatistics{
+			DataScannedInBytes:     aws.Int64(1024),
+			ResultReuseInformation: &types.ResultReuseInformation{ReusedPreviousResult: true},
+		},
+	}}, nil
+}
+
+func (c *fakeClient) GetQueryResults(_ context.Context, input *athena.GetQueryResultsInput, _ ...func(*athena.Options)) (*athena.GetQueryResultsOutput, error) {
+	return &athena.GetQueryResultsOutput{
+		NextToken: aws.String("next"),
+		ResultSet: &types.ResultSet{
+			ResultSetMetadata: &types.ResultSetMetadata{ColumnInfo: []types.ColumnInfo{
+				{Name: aws.String("n"), Type: aws.String("integer"), Precision: 10},
+			}},
+			Rows: []types.Row{
+				{Data: []types.Datum{{VarCharValue: aws.String("n")}}},
+				{Data: []types.Datum{{VarCharValue: aws.String("1")}, {}}},
+			},
+		},
+	}, nil
+}
+
+func TestAPI_StartQueryExecution(t *testing.T) {
+	client := &fakeClient{}
+	api := NewFromClient(client)
+
+	out, err := api.StartQueryExecution(context.Background(), &goathena.StartQueryExecutionInput{
+		Query:             "SELECT 1",
+		Database:          "db",
+		WorkGroup:         "primary",
+		OutputLocation:    "s3://results",
+		S3ACLOption:       goathena.S3ACLBucketOwnerFullControl,
+		Encryption:        &goathena.Encryption{Option: goathena.EncryptionSSEKMS, KMSKey: "key"},
+		ResultReuseMaxAge: time.Hour,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "id", out.QueryID)
+
+	started := client.started
+	assert.Equal(t, "SELECT 1", aws.ToString(started.QueryString))
+	assert.Equal(t, "db", aws.ToString(started.QueryExecutionContext.Database))
+	assert.Nil(t, started.QueryExecutionContext.Catalog)
+	assert.Equal(t, "primary", aws.ToString(started.WorkGroup))
+	assert.Equal(t, "s3://results", aws.ToString(started.ResultConfiguration.OutputLocation))
+	assert.Equal(t, types.S3AclOptionBucketOwnerFullControl, started.ResultConfiguration.AclConfiguration.S3AclOption)
+	assert.Equal(t, types.EncryptionOptionSseKms, started.ResultConfiguration.EncryptionConfiguration.EncryptionOption)
+	assert.Equal(t, "key", aws.ToString(started.ResultConfiguration.EncryptionConfiguration.KmsKey))
+	assert.Nil(t, started.ClientRequestToken)
+	assert.True(t, started.ResultReuseConfiguration.ResultReuseByAgeConfiguration.Enabled)
+	assert.Equal(t, int32(60), aws.ToInt32(started.ResultReuseConfiguration.ResultReuseByAgeConfiguration.MaxAgeInMinutes))
+}
+
+func TestAPI_GetQueryExecution(t *testing.T) {
+	api := NewFromClient(&fakeClient{})
+
+	qe, err := api.GetQueryExecution(context.Background(), &goathena.GetQueryExecutionInput{QueryID: "id"})
+	require.NoError(t, err)
+	assert.Equal(t, "id", qe.QueryID)
+	assert.Equal(t, goathena.StatementTypeDML, qe.StatementType)
+	assert.Equal(t, goathena.QueryStateFailed, qe.Status.State)
+	assert.Equal(t, "syntax error", qe.Status.StateChangeReason)
+	assert.Equal(t, time.Unix(0, 0), qe.Status.CompletionDateTime)
+	assert.Equal(t, &goathena.AthenaError{ErrorCategory: 2, ErrorType: 1000}, qe.Status.AthenaError)
+	assert.Equal(t, int64(1024), qe.Statistics.DataScannedInBytes)
+	assert.True(t, qe.Statistics.ResultReused)
+}
+
+func TestAPI_GetQueryResults(t *testing.T) {
+	api := NewFromClient(&fakeClient{})
+
+	out, err := api.GetQueryResults(context.Background(), &goathena.GetQueryResultsInput{QueryID: "id"})
+	require.NoError(t, err)
+	assert.Equal(t, "next", out.NextToken)
+	assert.Equal(t, []goathena.ColumnInfo{{Name: "n", Type: "integer", Precision: 10}}, out.Columns)
+	require.Len(t, out.Rows, 2)
+	assert.Equal(t, "1", aws.ToString(out.Rows[1][0]))
+	assert.Nil(t, out.Rows[1][1])
+}
+
+func TestAPI_Error(t *testing.T) {
+	api := NewFromClient(&fakeClient{err: &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}})
+
+	_, err := api.StartQueryExecution(context.Background(), &goathena.StartQueryExecutionInput{Query: "SELECT 1"})
+	var awsErr awserr.Error
+	require.ErrorAs(t, err, &awsErr)
+	assert.Equal(t, "ThrottlingException", awsErr.Code())
+	assert.Equal(t, "Rate exceeded", awsErr.Message())
+	assert.True(t, goathena.IsThrottlingError(err))
+}
+
+type fakeS3Client struct {
+	S3Client
+	deleted *s3.DeleteObjectsInput
+}
+
+func (c *fakeS3Client) GetObject(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if aws.ToString(input.Key) == "missing" {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchKey", Message: "not found"}
+	}
+	body := aws.ToString(input.Bucket) + "/" + aws.ToString(input.Key) + " " + aws.ToString(input.Range)
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: aws.Int64(int64(len(body))),
+	}, nil
+}
+
+func (c *fakeS3Client) HeadObject(_ context.Context, input *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	return &s3.HeadObjectOutput{ContentLength: aws.Int64(42)}, nil
+}
+
+func (c *fakeS3Client) ListObjectsV2(_ context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	return &s3.ListObjectsV2Output{
+		Contents:              []s3types.Object{{Key: aws.String(aws.ToString(input.Prefix) + "a")}},
+		IsTruncated:           aws.Bool(true),
+		NextContinuationToken: aws.String("next"),
+	}, nil
+}
+
+func (c *fakeS3Client) DeleteObjects(_ context.Context, input *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	c.deleted = input
+	return &s3.DeleteObjectsOutput{Errors: []s3types.Error{{Key: aws.String("b"), Message: aws.String("denied")}}}, nil
+}
+
+func TestS3API(t *testing.T) {
+	client := &fakeS3Client{}
+	api := NewS3FromClient(client)
+	ctx := context.Background()
+
+	got, err := api.GetObject(ctx, &goathena.S3GetObjectInput{Bucket: "bucket", Key: "key", Range: "bytes=0-9"})
+	require.NoError(t, err)
+	body, err := io.ReadAll(got.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "bucket/key bytes=0-9", string(body))
+
+	_, err = api.GetObject(ctx, &goathena.S3GetObjectInput{Bucket: "bucket", Key: "missing"})
+	var awsErr awserr.Error
+	require.ErrorAs(t, err, &awsErr)
+	assert.Equal(t, "NoSuchKey", awsErr.Code())
+
+	head, err := api.HeadObject(ctx, &goathena.S3HeadObjectInput{Bucket: "bucket", Key: "key"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 42, head.ContentLength)
+
+	list, err := api.ListObjectsV2(ctx, &goathena.S3ListObjectsInput{Bucket: "bucket", Prefix: "prefix/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prefix/a"}, list.Keys)
+	assert.Equal(t, "next", list.NextContinuationToken)
+
+	deleted, err := api.DeleteObjects(ctx, &goathena.S3DeleteObjectsInput{Bucket: "bucket", Keys: []string{"a", "b"}})
+	require.NoError(t, err)
+	assert.Equal(t, []s3types.ObjectIdentifier{{Key: aws.String("a")}, {Key: aws.String("b")}}, client.deleted.Delete.Objects)
+	assert.True(t, aws.ToBool(client.deleted.Delete.Quiet))
+	assert.Equal(t, []goathena.S3DeleteError{{Key: "b", Message: "denied"}}, deleted.Errors)
+}
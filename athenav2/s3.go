@@ -0,0 +1,108 @@
+package athenav2
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	goathena "github.com/segmentio/go-athena"
+)
+
+// S3Client is the part of *s3.Client the driver calls, to read the results
+// of queries run with goathena.ResultModeUnload.
+type S3Client interface {
+	GetObject(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	HeadObject(ctx context.Context, input *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	DeleteObjects(ctx context.Context, input *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+var _ S3Client = (*s3.Client)(nil)
+
+// NewS3 returns a goathena.S3API, for Config.S3, that calls S3 with a client
+// for cfg.
+func NewS3(cfg aws.Config, optFns ...func(*s3.Options)) goathena.S3API {
+	return NewS3FromClient(s3.NewFromConfig(cfg, optFns...))
+}
+
+// NewS3FromClient returns a goathena.S3API that calls S3 with client.
+func NewS3FromClient(client S3Client) goathena.S3API {
+	return s3API{client: client}
+}
+
+type s3API struct {
+	client S3Client
+}
+
+func (a s3API) GetObject(ctx context.Context, input *goathena.S3GetObjectInput) (*goathena.S3GetObjectOutput, error) {
+	in := &s3.GetObjectInput{
+		Bucket: aws.String(input.Bucket),
+		Key:    aws.String(input.Key),
+	}
+	if input.Range != "" {
+		in.Range = aws.String(input.Range)
+	}
+	out, err := a.client.GetObject(ctx, in)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return &goathena.S3GetObjectOutput{Body: out.Body}, nil
+}
+
+func (a s3API) HeadObject(ctx context.Context, input *goathena.S3HeadObjectInput) (*goathena.S3HeadObjectOutput, error) {
+	out, err := a.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(input.Bucket),
+		Key:    aws.String(input.Key),
+	})
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return &goathena.S3HeadObjectOutput{ContentLength: aws.ToInt64(out.ContentLength)}, nil
+}
+
+func (a s3API) ListObjectsV2(ctx context.Context, input *goathena.S3ListObjectsInput) (*goathena.S3ListObjectsOutput, error) {
+	in := &s3.ListObjectsV2Input{
+		Bucket: aws.String(input.Bucket),
+		Prefix: aws.String(input.Prefix),
+	}
+	if input.ContinuationToken != "" {
+		in.ContinuationToken = aws.String(input.ContinuationToken)
+	}
+	out, err := a.client.ListObjectsV2(ctx, in)
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	var result goathena.S3ListObjectsOutput
+	for _, object := range out.Contents {
+		result.Keys = append(result.Keys, aws.ToString(object.Key))
+	}
+	if aws.ToBool(out.IsTruncated) {
+		result.NextContinuationToken = aws.ToString(out.NextContinuationToken)
+	}
+	return &result, nil
+}
+
+func (a s3API) DeleteObjects(ctx context.Context, input *goathena.S3DeleteObjectsInput) (*goathena.S3DeleteObjectsOutput, error) {
+	objects := make([]types.ObjectIdentifier, len(input.Keys))
+	for i, key := range input.Keys {
+		objects[i] = types.ObjectIdentifier{Key: aws.String(key)}
+	}
+	out, err := a.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(input.Bucket),
+		Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+	})
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	var result goathena.S3DeleteObjectsOutput
+	for _, failed := range out.Errors {
+		result.Errors = append(result.Errors, goathena.S3DeleteError{
+			Key:     aws.ToString(failed.Key),
+			Message: aws.ToString(failed.Message),
+		})
+	}
+	return &result, nil
+}
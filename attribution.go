@@ -0,0 +1,100 @@
+package athena
+
+import (
+	"context"
+	"path"
+	"runtime"
+	"strings"
+)
+
+// Attribution makes the driver prepend a comment to every query saying where
+// it came from, so it can be told apart in Athena's query history, e.g.
+//
+//	/* app=billing, request_id=42, caller=api.(*Server).ListInvoices */ SELECT ...
+//
+// The comment is made of App, the pairs added to the query's context with
+// athena.WithAttribution(), and the function that ran the query, in that order.
+type Attribution struct {
+	// App names the application running queries.
+	App string
+
+	// Caller adds the function that ran the query, when it's known. That's
+	// the first function on the stack outside of this package and
+	// database/sql, so it's the caller of db.Query(), Client.StartQuery(), etc.
+	Caller bool
+
+	// Comment, if set, replaces the comment described above with the one it
+	// returns for a query's context. It's not prepended if it's empty.
+	Comment func(ctx context.Context) string
+}
+
+// queryComment returns the comment to prepend to a query run with ctx, or an
+// empty string if there's none.
+func (c *conn) queryComment(ctx context.Context) string {
+	a := c.attribution
+	if a == nil {
+		// Attribution added to the context is still honored.
+		a = &Attribution{}
+	}
+	if a.Comment != nil {
+		return a.Comment(ctx)
+	}
+
+	var pairs []string
+	if a.App != "" {
+		pairs = append(pairs, "app="+a.App)
+	}
+	for _, kv := range attributionFromContext(ctx) {
+		pairs = append(pairs, kv.key+"="+kv.value)
+	}
+	if a.Caller {
+		if caller := queryCaller(); caller != "" {
+			pairs = append(pairs, "caller="+caller)
+		}
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// withComment prepends comment to query as a block comment. Athena's parser
+// skips it, as does the driver's.
+func withComment(query, comment string) string {
+	if comment == "" {
+		return query
+	}
+	// Don't let the comment end early.
+	comment = strings.ReplaceAll(comment, "*/", "* /")
+	return "/* " + comment + " */ " + query
+}
+
+// queryCaller returns the name of the function that ran a query, as its
+// package name followed by the function name, or "" if it can't be found.
+func queryCaller() string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isDriverFrame(frame.Function) {
+			return path.Base(frame.Function)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// isDriverFrame reports whether function is part of the machinery running a
+// query rather than the code that asked for it.
+func isDriverFrame(function string) bool {
+	for _, prefix := range []string{
+		"github.com/segmentio/go-athena.",
+		"database/sql.",
+		"runtime.",
+		"context.",
+	} {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package athena
+
+import (
+	"context"
+	"testing"
+
+	"github.com/segmentio/go-athena/presto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttribution(t *testing.T) {
+	mock := &mockAsyncAthenaClient{}
+	client := newMockClient(mock)
+
+	ctx := WithAttribution(context.Background(), "request_id", "42")
+	ctx = WithAttribution(ctx, "trace_id", "abc")
+
+	_, err := client.StartQuery(ctx, "select")
+	require.NoError(t, err)
+
+	client.conn.attribution = &Attribution{App: "billing", Caller: true}
+	_, err = client.StartQuery(ctx, "select")
+	require.NoError(t, err)
+
+	client.conn.attribution = &Attribution{
+		App: "ignored",
+		Comment: func(ctx context.Context) string {
+			return "custom */ comment"
+		},
+	}
+	_, err = client.StartQuery(ctx, "select")
+	require.NoError(t, err)
+
+	require.Len(t, mock.started, 3)
+	assert.Equal(t, "/* request_id=42, trace_id=abc */ select", mock.started[0].Query)
+	// The test itself is in the driver's package, so it's skipped too.
+	assert.Equal(t, "/* app=billing, request_id=42, trace_id=abc, caller=testing.tRunner */ select", mock.started[1].Query)
+	assert.Equal(t, "/* custom * / comment */ select", mock.started[2].Query)
+}
+
+func TestWithComment_Syntax(t *testing.T) {
+	query := withComment("SELECT * FROM t WHERE id = $1", "app=billing, caller=api.(*Server).List */ DROP")
+	_, err := presto.ValidateAndFormatSql(query, 1)
+	assert.NoError(t, err)
+}
+
+func TestWithClientRequestToken(t *testing.T) {
+	mock := &mockAsyncAthenaClient{}
+	client := newMockClient(mock)
+
+	token := "0123456789abcdef0123456789abcdef"
+	_, err := client.StartQuery(WithClientRequestToken(context.Background(), token), "select")
+	require.NoError(t, err)
+	_, err = client.StartQuery(context.Background(), "select")
+	require.NoError(t, err)
+
+	require.Len(t, mock.started, 2)
+	assert.Equal(t, token, mock.started[0].ClientRequestToken)
+	assert.Empty(t, mock.started[1].ClientRequestToken)
+}
@@ -0,0 +1,263 @@
+package athena
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/segmentio/go-athena/presto"
+)
+
+// maxBatchGetQueryExecution is the most query IDs BatchGetQueryExecution accepts per call.
+const maxBatchGetQueryExecution = 50
+
+// maxUnprocessedPolls is how many polls in a row a query can be left
+// unprocessed by BatchGetQueryExecution before it's stopped and reported as
+// failed.
+const maxUnprocessedPolls = 5
+
+// maxPollBackoff is the longest RunBatch() waits to poll again after polling
+// failed.
+const maxPollBackoff = 30 * time.Second
+
+// BatchQuery is a single query for RunBatch().
+type BatchQuery struct {
+	Query string
+	Args  []interface{}
+}
+
+// BatchResult is the outcome of a single query run by RunBatch().
+// Exactly one of Results and Err is set. Results must be closed by the caller.
+type BatchResult struct {
+	// Index is the query's position in the slice passed to RunBatch().
+	Index   int
+	QueryID string
+	Stats   QueryStats
+
+	Results *Results
+	Err     error
+}
+
+// BatchOptions configures RunBatch().
+type BatchOptions struct {
+	// Concurrency is the most queries that will be running at once.
+	// It defaults to 10.
+	Concurrency int
+
+	// PollFrequency is how often the in-flight queries are polled.
+	// It defaults to the Client's poll frequency.
+	PollFrequency time.Duration
+}
+
+// RunBatch runs queries with bounded concurrency. Rather than polling each
+// query separately, all in-flight queries are polled together with
+// BatchGetQueryExecution.
+//
+// A result is sent on the returned channel as each query finishes, in no
+// particular order, and the channel is closed once every query has finished.
+// If ctx is done, in-flight queries are stopped and every query that hasn't
+// finished yet is reported with ctx's error. The same happens if polling
+// fails, unless the error is a transient one such as throttling, in which
+// case polling is retried with exponential backoff. Throttled polls lower
+// Config.Admission's concurrency limit like throttled queries do.
+func (c *Client) RunBatch(ctx context.Context, queries []BatchQuery, opts BatchOptions) <-chan BatchResult {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 10
+	}
+	if opts.PollFrequency == 0 {
+		opts.PollFrequency = c.conn.pollFrequency
+	}
+
+	results := make(chan BatchResult, len(queries))
+	go func() {
+		defer close(results)
+		b := batch{
+			client:      c,
+			opts:        opts,
+			queries:     queries,
+			results:     results,
+			hooks:       c.conn.hooksFor(ctx),
+			inflight:    make(map[string]int),
+			states:      make(map[string]string),
+			unprocessed: make(map[string]int),
+		}
+		b.run(ctx)
+	}()
+
+	return results
+}
+
+type batch struct {
+	client  *Client
+	opts    BatchOptions
+	queries []BatchQuery
+	results chan<- BatchResult
+
+	hooks Hooks
+
+	next     int
+	inflight map[string]int
+	// states holds the last state seen of each in-flight query.
+	states map[string]string
+	// unprocessed holds how many polls in a row each in-flight query was
+	// left unprocessed by.
+	unprocessed map[string]int
+}
+
+func (b *batch) run(ctx context.Context) {
+	// failures is how many polls in a row failed.
+	failures := 0
+	for {
+		b.startQueries(ctx)
+		if len(b.inflight) == 0 && b.next == len(b.queries) {
+			return
+		}
+
+		wait := b.opts.PollFrequency
+		if failures > 0 {
+			wait = min(wait<<min(failures, 16), maxPollBackoff)
+		}
+		select {
+		case <-ctx.Done():
+			b.abort(ctx, ctx.Err())
+			return
+		case <-time.After(wait):
+		}
+
+		if err := b.poll(ctx); err != nil {
+			b.client.conn.admission.throttled(err)
+			if ctx.Err() != nil || !isRetryableError(err) {
+				b.abort(ctx, err)
+				return
+			}
+			failures++
+			continue
+		}
+		failures = 0
+	}
+}
+
+// startQueries starts as many pending queries as the concurrency limit allows.
+func (b *batch) startQueries(ctx context.Context) {
+	for len(b.inflight) < b.opts.Concurrency && b.next < len(b.queries) {
+		index := b.next
+		b.next++
+
+		query := b.queries[index]
+		sql := query.Query
+		if len(query.Args) > 0 {
+			var err error
+			sql, err = presto.ValidateAndFormatSql(sql, query.Args...)
+			if err != nil {
+				b.results <- BatchResult{Index: index, Err: err}
+				continue
+			}
+		}
+
+		queryID, err := b.client.conn.startAdmittedQuery(ctx, sql)
+		if err != nil {
+			b.results <- BatchResult{Index: index, Err: err}
+			continue
+		}
+
+		b.inflight[queryID] = index
+		running.add(b.client.conn, queryID, false)
+	}
+}
+
+// poll checks on every in-flight query and reports the ones that finished.
+func (b *batch) poll(ctx context.Context) error {
+	queryIDs := make([]string, 0, len(b.inflight))
+	for queryID := range b.inflight {
+		queryIDs = append(queryIDs, queryID)
+	}
+
+	for len(queryIDs) > 0 {
+		n := len(queryIDs)
+		if n > maxBatchGetQueryExecution {
+			n = maxBatchGetQueryExecution
+		}
+
+		resp, err := b.client.conn.athena.BatchGetQueryExecution(ctx, &BatchGetQueryExecutionInput{
+			QueryIDs: queryIDs[:n],
+		})
+		if err != nil {
+			for _, queryID := range queryIDs[:n] {
+				b.hooks.pollFailed(ctx, queryID, nil, err)
+			}
+			return err
+		}
+		queryIDs = queryIDs[n:]
+
+		for _, execution := range resp.QueryExecutions {
+			queryID := execution.QueryID
+			delete(b.unprocessed, queryID)
+			b.states[queryID] = b.hooks.poll(ctx, execution, b.states[queryID])
+
+			if done, err := queryDone(execution); done {
+				b.finish(ctx, queryID, execution, err)
+			} else if err := b.client.conn.checkScanBudget(ctx, execution); err != nil {
+				b.finish(ctx, queryID, execution, err)
+			}
+		}
+
+		// Queries are usually left unprocessed by transient errors, so they're
+		// polled again next time, and only given up on if it keeps happening.
+		for _, unprocessed := range resp.Unprocessed {
+			queryID := unprocessed.QueryID
+			if _, ok := b.inflight[queryID]; !ok {
+				continue
+			}
+			err := errors.New(unprocessed.ErrorMessage)
+			b.hooks.pollFailed(ctx, queryID, nil, err)
+			b.unprocessed[queryID]++
+			if b.unprocessed[queryID] < maxUnprocessedPolls {
+				continue
+			}
+			b.client.conn.stopQuery(queryID)
+			b.finish(ctx, queryID, nil, err)
+		}
+	}
+
+	return nil
+}
+
+func (b *batch) finish(ctx context.Context, queryID string, execution *QueryExecution, err error) {
+	index, ok := b.inflight[queryID]
+	if !ok {
+		return
+	}
+	delete(b.inflight, queryID)
+	delete(b.states, queryID)
+	delete(b.unprocessed, queryID)
+	running.remove(queryID)
+	b.client.conn.logger.finished(ctx, queryID, execution, err)
+	b.hooks.complete(ctx, queryID, execution, err)
+
+	result := BatchResult{Index: index, QueryID: queryID, Err: err}
+	if execution != nil {
+		result.Stats = newQueryStats(execution)
+	}
+	if err == nil {
+		result.Results, result.Err = b.client.openResults(ctx, execution)
+	}
+
+	b.results <- result
+}
+
+// abort stops every in-flight query and reports err for them and for the
+// queries that were never started.
+func (b *batch) abort(ctx context.Context, err error) {
+	for queryID, index := range b.inflight {
+		b.client.conn.stopQuery(queryID)
+		running.remove(queryID)
+		b.client.conn.logger.finished(ctx, queryID, nil, err)
+		b.hooks.complete(ctx, queryID, nil, err)
+		b.results <- BatchResult{Index: index, QueryID: queryID, Err: err}
+	}
+	b.inflight = nil
+
+	for ; b.next < len(b.queries); b.next++ {
+		b.results <- BatchResult{Index: b.next, Err: err}
+	}
+}
@@ -0,0 +1,210 @@
+package athena
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (m *mockAsyncAthenaClient) BatchGetQueryExecution(ctx context.Context, input *BatchGetQueryExecutionInput) (*BatchGetQueryExecutionOutput, error) {
+	var out BatchGetQueryExecutionOutput
+	for _, queryID := range input.QueryIDs {
+		m.mu.Lock()
+		_, ok := m.states[queryID]
+		m.mu.Unlock()
+		if !ok {
+			out.Unprocessed = append(out.Unprocessed, UnprocessedQueryExecution{
+				QueryID:      queryID,
+				ErrorMessage: "unknown query",
+			})
+			continue
+		}
+
+		execution, _ := m.GetQueryExecution(ctx, &GetQueryExecutionInput{QueryID: queryID})
+		out.QueryExecutions = append(out.QueryExecutions, execution)
+	}
+	return &out, nil
+}
+
+func TestClient_RunBatch(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"select": {QueryStateRunning, QueryStateSucceeded},
+		"show":   {QueryStateSucceeded},
+		"failed": {QueryStateQueued, QueryStateFailed},
+	}}
+	client := newMockClient(mock)
+
+	queries := []BatchQuery{
+		{Query: "select"},
+		{Query: "show"},
+		{Query: "failed"},
+		{Query: "unknown"},
+		{Query: "select ?", Args: []interface{}{struct{}{}}},
+	}
+
+	var results []BatchResult
+	for result := range client.RunBatch(context.Background(), queries, BatchOptions{Concurrency: 2}) {
+		results = append(results, result)
+	}
+	require.Len(t, results, len(queries))
+	sort.Slice(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+
+	cnt := 0
+	require.NoError(t, results[0].Err)
+	for results[0].Results.Next() {
+		cnt++
+	}
+	assert.Equal(t, 9, cnt)
+
+	require.NoError(t, results[1].Err)
+	assert.Equal(t, "show", results[1].QueryID)
+
+	assert.EqualError(t, results[2].Err, "reason")
+	assert.EqualError(t, results[3].Err, "unknown query")
+	assert.Equal(t, []string{"unknown"}, mock.stopped, "unprocessed query wasn't stopped")
+	assert.Error(t, results[4].Err, "unsupported parameter type")
+	assert.Empty(t, results[4].QueryID)
+}
+
+// flakyBatchAthenaClient leaves queries unprocessed by
+// BatchGetQueryExecution the first few times they're polled.
+type flakyBatchAthenaClient struct {
+	*mockAsyncAthenaClient
+	unprocessed map[string]int
+}
+
+func (m *flakyBatchAthenaClient) BatchGetQueryExecution(ctx context.Context, input *BatchGetQueryExecutionInput) (*BatchGetQueryExecutionOutput, error) {
+	var processed []string
+	var out BatchGetQueryExecutionOutput
+	for _, queryID := range input.QueryIDs {
+		if m.unprocessed[queryID] > 0 {
+			m.unprocessed[queryID]--
+			out.Unprocessed = append(out.Unprocessed, UnprocessedQueryExecution{
+				QueryID:      queryID,
+				ErrorMessage: "throttled",
+			})
+			continue
+		}
+		processed = append(processed, queryID)
+	}
+
+	resp, err := m.mockAsyncAthenaClient.BatchGetQueryExecution(ctx, &BatchGetQueryExecutionInput{QueryIDs: processed})
+	if err != nil {
+		return nil, err
+	}
+	out.QueryExecutions = resp.QueryExecutions
+	return &out, nil
+}
+
+func TestClient_RunBatch_Unprocessed(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"select": {QueryStateRunning, QueryStateSucceeded},
+		"show":   {QueryStateRunning},
+	}}
+	client := &Client{conn: &conn{
+		athena: &flakyBatchAthenaClient{
+			mockAsyncAthenaClient: mock,
+			unprocessed:           map[string]int{"select": maxUnprocessedPolls - 1, "show": maxUnprocessedPolls},
+		},
+		pollFrequency: time.Millisecond,
+	}}
+
+	results := map[string]BatchResult{}
+	for result := range client.RunBatch(context.Background(), []BatchQuery{{Query: "select"}, {Query: "show"}}, BatchOptions{}) {
+		results[result.QueryID] = result
+	}
+	require.Len(t, results, 2)
+
+	// Queries left unprocessed a few times are polled again.
+	require.NoError(t, results["select"].Err)
+	results["select"].Results.Close()
+
+	// Queries left unprocessed for good are stopped before being reported.
+	assert.EqualError(t, results["show"].Err, "throttled")
+	assert.Equal(t, []string{"show"}, mock.stopped)
+}
+
+// failingBatchAthenaClient fails the first few BatchGetQueryExecution calls
+// with err.
+type failingBatchAthenaClient struct {
+	*mockAsyncAthenaClient
+	failures int
+	err      error
+}
+
+func (m *failingBatchAthenaClient) BatchGetQueryExecution(ctx context.Context, input *BatchGetQueryExecutionInput) (*BatchGetQueryExecutionOutput, error) {
+	if m.failures > 0 {
+		m.failures--
+		return nil, m.err
+	}
+	return m.mockAsyncAthenaClient.BatchGetQueryExecution(ctx, input)
+}
+
+func TestClient_RunBatch_PollErrors(t *testing.T) {
+	newClient := func(failures int, err error) (*Client, *mockAsyncAthenaClient) {
+		mock := &mockAsyncAthenaClient{states: map[string][]string{
+			"select": {QueryStateSucceeded},
+		}}
+		return &Client{conn: &conn{
+			athena:        &failingBatchAthenaClient{mockAsyncAthenaClient: mock, failures: failures, err: err},
+			pollFrequency: time.Millisecond,
+			admission:     NewAdmissionController(AdmissionConfig{MaxConcurrency: 8}),
+		}}, mock
+	}
+
+	// Throttled polls are retried, and lower the concurrency limit.
+	client, mock := newClient(3, awserr.New("ThrottlingException", "slow down", nil))
+	var results []BatchResult
+	for result := range client.RunBatch(context.Background(), []BatchQuery{{Query: "select"}}, BatchOptions{}) {
+		results = append(results, result)
+	}
+	require.Len(t, results, 1)
+	require.NoError(t, results[0].Err)
+	results[0].Results.Close()
+	assert.Empty(t, mock.stopped)
+	assert.Equal(t, int64(3), client.conn.admission.Stats().Throttled)
+	assert.Equal(t, 1, client.conn.admission.Stats().ConcurrencyLimit)
+
+	client, _ = newClient(2, awserr.NewRequestFailure(awserr.New("InternalServerException", "oops", nil), 500, "id"))
+	results = nil
+	for result := range client.RunBatch(context.Background(), []BatchQuery{{Query: "select"}}, BatchOptions{}) {
+		results = append(results, result)
+	}
+	require.Len(t, results, 1)
+	require.NoError(t, results[0].Err)
+	results[0].Results.Close()
+	assert.Equal(t, int64(0), client.conn.admission.Stats().Throttled)
+
+	// Other errors abort the batch.
+	client, mock = newClient(1, dummyError)
+	results = nil
+	for result := range client.RunBatch(context.Background(), []BatchQuery{{Query: "select"}, {Query: "show"}}, BatchOptions{Concurrency: 1}) {
+		results = append(results, result)
+	}
+	require.Len(t, results, 2)
+	assert.Equal(t, dummyError, results[0].Err)
+	assert.Equal(t, dummyError, results[1].Err)
+	assert.Equal(t, []string{"select"}, mock.stopped)
+}
+
+func TestClient_RunBatch_Cancel(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"select": {QueryStateRunning},
+	}}
+	client := newMockClient(mock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var errs []error
+	for result := range client.RunBatch(ctx, []BatchQuery{{Query: "select"}, {Query: "show"}}, BatchOptions{Concurrency: 1}) {
+		errs = append(errs, result.Err)
+	}
+	assert.Equal(t, []error{context.Canceled, context.Canceled}, errs)
+	assert.Equal(t, []string{"select"}, mock.stopped)
+}
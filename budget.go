@@ -0,0 +1,43 @@
+package athena
+
+import (
+	"context"
+	"fmt"
+)
+
+// ErrScanBudgetExceeded is returned for queries stopped because they scanned
+// more than Config.MaxBytesScanned.
+type ErrScanBudgetExceeded struct {
+	QueryID string
+	// BytesScanned is how much the query had scanned when it was stopped.
+	BytesScanned    int64
+	MaxBytesScanned int64
+}
+
+func (e *ErrScanBudgetExceeded) Error() string {
+	return fmt.Sprintf("query %s stopped after scanning %d bytes, more than the limit of %d", e.QueryID, e.BytesScanned, e.MaxBytesScanned)
+}
+
+// checkScanBudget stops a running query if it has scanned more than allowed.
+func (c *conn) checkScanBudget(ctx context.Context, execution *QueryExecution) error {
+	limit, ok := maxBytesScannedFromContext(ctx)
+	if !ok {
+		limit = c.maxBytesScanned
+	}
+	if limit <= 0 {
+		return nil
+	}
+
+	scanned := execution.Statistics.DataScannedInBytes
+	if scanned <= limit {
+		return nil
+	}
+
+	queryID := execution.QueryID
+	c.stopQuery(queryID)
+	return &ErrScanBudgetExceeded{
+		QueryID:         queryID,
+		BytesScanned:    scanned,
+		MaxBytesScanned: limit,
+	}
+}
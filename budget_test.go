@@ -0,0 +1,35 @@
+package athena
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConn_MaxBytesScanned(t *testing.T) {
+	mock := &mockAsyncAthenaClient{
+		states: map[string][]string{
+			"select": {QueryStateRunning},
+		},
+		statistics: QueryExecutionStatistics{
+			DataScannedInBytes: 2048,
+		},
+	}
+	c := &conn{athena: mock, pollFrequency: time.Millisecond, maxBytesScanned: 1024}
+
+	_, err := c.QueryContext(context.Background(), "select", nil)
+	require.IsType(t, &ErrScanBudgetExceeded{}, err)
+	assert.Equal(t, &ErrScanBudgetExceeded{
+		QueryID:         "select",
+		BytesScanned:    2048,
+		MaxBytesScanned: 1024,
+	}, err)
+	assert.Equal(t, []string{"select"}, mock.stopped)
+
+	mock.states["select"] = []string{QueryStateRunning, QueryStateSucceeded}
+	_, err = c.QueryContext(WithMaxBytesScanned(context.Background(), 4096), "select", nil)
+	assert.NoError(t, err, "context should override the limit")
+}
@@ -0,0 +1,306 @@
+package athena
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/segmentio/go-athena/presto"
+)
+
+// ResultCache stores complete query results so that repeated queries don't
+// have to run on Athena again. Implementations must be safe for concurrent use.
+//
+// The driver never returns expired results, so implementations may keep them
+// around until they're overwritten or evicted.
+type ResultCache interface {
+	Get(key string) (*CachedResult, bool)
+	Set(key string, result *CachedResult) error
+}
+
+// CachedResult is a query's complete result set.
+type CachedResult struct {
+	Columns []CachedColumn
+	// Rows holds values as Athena returned them, before any type conversion.
+	// A nil value is NULL.
+	Rows      [][]*string
+	ExpiresAt time.Time
+}
+
+// CachedColumn describes a column of a CachedResult.
+type CachedColumn struct {
+	Name string
+	Type string
+
+	CatalogName string `json:",omitempty"`
+	SchemaName  string `json:",omitempty"`
+	TableName   string `json:",omitempty"`
+}
+
+func (r *CachedResult) expired() bool {
+	return !time.Now().Before(r.ExpiresAt)
+}
+
+// resultCacheKey identifies a query by its normalized SQL, its parameters and
+// the database it runs in.
+func resultCacheKey(db string, query string, params []interface{}) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s", db, presto.Normalize(query))
+	for _, p := range params {
+		fmt.Fprintf(h, "\x00%#v", p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// defaultCacheMaxRows is how many rows a result can have to be cached, unless
+// Config.CacheMaxRows says otherwise.
+const defaultCacheMaxRows = 10000
+
+// cacheRows reads all of r into the cache and returns an iterator over them.
+// If r has more than cacheMaxRows rows, the ones read so far are returned
+// followed by the rest of r, and nothing is cached.
+func (c *conn) cacheRows(key string, r *rows) (driver.Rows, error) {
+	result := CachedResult{ExpiresAt: time.Now().Add(c.cacheTTL)}
+	for _, colInfo := range r.out.Columns {
+		result.Columns = append(result.Columns, CachedColumn{
+			Name:        colInfo.Name,
+			Type:        colInfo.Type,
+			CatalogName: colInfo.CatalogName,
+			SchemaName:  colInfo.SchemaName,
+			TableName:   colInfo.TableName,
+		})
+	}
+
+	maxRows := c.cacheMaxRows
+	if maxRows == 0 {
+		maxRows = defaultCacheMaxRows
+	}
+
+	for {
+		row, err := r.nextRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+
+		result.Rows = append(result.Rows, row)
+
+		if len(result.Rows) > maxRows {
+			cached := newCachedRows(&result)
+			cached.rest = r
+			return cached, nil
+		}
+	}
+	r.Close()
+
+	// Failing to cache results shouldn't fail the query. Results cut short by
+	// MaxRows aren't cached, as the limit isn't part of the key.
+	if !r.truncated {
+		_ = c.cache.Set(key, &result)
+	}
+
+	return newCachedRows(&result), nil
+}
+
+type cachedRows struct {
+	result *CachedResult
+	next   int
+
+	// rest, if set, holds the rows left after the result's.
+	rest *rows
+}
+
+func newCachedRows(result *CachedResult) *cachedRows {
+	return &cachedRows{result: result}
+}
+
+func (r *cachedRows) Columns() []string {
+	var columns []string
+	for _, col := range r.result.Columns {
+		columns = append(columns, col.Name)
+	}
+	return columns
+}
+
+func (r *cachedRows) ColumnInfo() []Column {
+	var columns []Column
+	for _, col := range r.result.Columns {
+		columns = append(columns, Column(col))
+	}
+	return columns
+}
+
+func (r *cachedRows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.result.Columns[index].Type
+}
+
+func (r *cachedRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.Rows) {
+		if r.rest != nil {
+			return r.rest.Next(dest)
+		}
+		return io.EOF
+	}
+
+	for i, raw := range r.result.Rows[r.next] {
+		coerced, err := convertValue(r.result.Columns[i].Type, raw)
+		if err != nil {
+			return err
+		}
+		dest[i] = coerced
+	}
+
+	r.next++
+	return nil
+}
+
+func (r *cachedRows) Close() error {
+	r.next = len(r.result.Rows)
+	if r.rest != nil {
+		return r.rest.Close()
+	}
+	return nil
+}
+
+// LRUCache is an in-memory ResultCache that holds a bounded number of results,
+// evicting the least recently used ones first.
+type LRUCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	entries    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key    string
+	result *CachedResult
+}
+
+// NewLRUCache returns an LRUCache holding at most maxEntries results. If
+// maxEntries is 0 or less, the cache isn't bounded, and only drops results
+// once they've expired and are looked up.
+func NewLRUCache(maxEntries int) *LRUCache {
+	return &LRUCache{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUCache) Get(key string) (*CachedResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if entry.result.expired() {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.result, true
+}
+
+func (c *LRUCache) Set(key string, result *CachedResult) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*lruEntry).result = result
+		c.order.MoveToFront(elem)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, result: result})
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+
+	return nil
+}
+
+var _ ResultCache = (*LRUCache)(nil)
+
+// DiskCache is a ResultCache that stores each result as a JSON file in a directory.
+// Expired results are deleted when they're next looked up.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a DiskCache storing results in dir, creating it if needed.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &DiskCache{dir: dir}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *DiskCache) Get(key string) (*CachedResult, bool) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var result CachedResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, false
+	}
+
+	if result.expired() {
+		os.Remove(path)
+		return nil, false
+	}
+
+	return &result, true
+}
+
+func (c *DiskCache) Set(key string, result *CachedResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial result.
+	tmp, err := os.CreateTemp(c.dir, key+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+var _ ResultCache = (*DiskCache)(nil)
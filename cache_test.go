@@ -0,0 +1,129 @@
+package athena
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConn_QueryContext_Cache(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"select": {QueryStateSucceeded},
+		"insert": {QueryStateSucceeded},
+	}}
+	c := &conn{
+		athena:        mock,
+		pollFrequency: time.Millisecond,
+		cache:         NewLRUCache(10),
+		cacheTTL:      time.Minute,
+	}
+
+	readAll := func(ctx context.Context, query string) [][]driver.Value {
+		r, err := c.QueryContext(ctx, query, nil)
+		require.NoError(t, err)
+		defer r.Close()
+
+		var values [][]driver.Value
+		for {
+			dest := make([]driver.Value, len(r.Columns()))
+			if err := r.Next(dest); err == io.EOF {
+				break
+			} else {
+				require.NoError(t, err)
+			}
+			values = append(values, dest)
+		}
+		return values
+	}
+
+	first := readAll(context.Background(), "select")
+	assert.Len(t, first, 9)
+
+	second := readAll(context.Background(), "select")
+	assert.Equal(t, first, second)
+	assert.Len(t, mock.started, 1, "second query should be cached")
+
+	readAll(WithoutCache(context.Background()), "select")
+	assert.Len(t, mock.started, 2)
+
+	// Statements other than queries always run.
+	readAll(context.Background(), "insert")
+	readAll(context.Background(), "insert")
+	assert.Len(t, mock.started, 4, "insert shouldn't be cached")
+
+	// Results with more rows than cacheMaxRows are streamed, not cached.
+	c.cache = NewLRUCache(10)
+	c.cacheMaxRows = 5
+	assert.Len(t, readAll(context.Background(), "select"), 9)
+	assert.Len(t, readAll(context.Background(), "select"), 9)
+	assert.Len(t, mock.started, 6, "large results shouldn't be cached")
+}
+
+func TestResultCacheKey(t *testing.T) {
+	key := resultCacheKey("db", "SELECT * FROM t WHERE a = ?", []interface{}{1})
+	assert.Equal(t, key, resultCacheKey("db", "select  *\nFROM t -- comment\nWHERE a = ?", []interface{}{1}))
+	assert.NotEqual(t, key, resultCacheKey("other_db", "SELECT * FROM t WHERE a = ?", []interface{}{1}))
+	assert.NotEqual(t, key, resultCacheKey("db", "SELECT * FROM t WHERE a = ?", []interface{}{2}))
+	assert.NotEqual(t, key, resultCacheKey("db", "SELECT * FROM t WHERE a = ?", []interface{}{"1"}))
+}
+
+func dummyCachedResult(ttl time.Duration) *CachedResult {
+	id := "1"
+	return &CachedResult{
+		Columns:   []CachedColumn{{Name: "id", Type: "integer"}},
+		Rows:      [][]*string{{&id}, {nil}},
+		ExpiresAt: time.Now().Add(ttl),
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := NewLRUCache(2)
+	require.NoError(t, cache.Set("a", dummyCachedResult(time.Minute)))
+	require.NoError(t, cache.Set("b", dummyCachedResult(time.Minute)))
+
+	_, ok := cache.Get("a")
+	assert.True(t, ok)
+
+	require.NoError(t, cache.Set("c", dummyCachedResult(time.Minute)))
+	_, ok = cache.Get("b")
+	assert.False(t, ok, "least recently used entry should be evicted")
+
+	require.NoError(t, cache.Set("a", dummyCachedResult(-time.Minute)))
+	_, ok = cache.Get("a")
+	assert.False(t, ok, "expired entry shouldn't be returned")
+
+	// A cache without a size isn't bounded.
+	cache = NewLRUCache(0)
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, cache.Set(key, dummyCachedResult(time.Minute)))
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		_, ok = cache.Get(key)
+		assert.True(t, ok, key)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	cache, err := NewDiskCache(t.TempDir())
+	require.NoError(t, err)
+
+	expected := dummyCachedResult(time.Minute)
+	require.NoError(t, cache.Set("a", expected))
+
+	result, ok := cache.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, expected.Rows, result.Rows)
+	assert.Equal(t, expected.Columns, result.Columns)
+
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+
+	require.NoError(t, cache.Set("a", dummyCachedResult(-time.Minute)))
+	_, ok = cache.Get("a")
+	assert.False(t, ok)
+}
@@ -3,30 +3,97 @@ package athena
 import (
 	"context"
 	"database/sql/driver"
-	"errors"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/athena"
-	"github.com/aws/aws-sdk-go/service/athena/athenaiface"
 	"github.com/segmentio/go-athena/presto"
 )
 
 type conn struct {
-	athena         athenaiface.AthenaAPI
+	athena         AthenaAPI
 	db             string
+	catalog        string
+	workGroup      string
 	OutputLocation string
 
+	encryption          *Encryption
+	expectedBucketOwner string
+	s3ACLOption         string
+
 	pollFrequency time.Duration
+	prefetchPages int
+	pageSize      int
+
+	maxRows       int64
+	stopAtMaxRows bool
+
+	resultMode     ResultMode
+	unloadLocation string
+	s3             S3API
+
+	cache        ResultCache
+	cacheTTL     time.Duration
+	cacheMaxRows int
+
+	resultReuseMaxAge time.Duration
+
+	admission *AdmissionController
+
+	maxBytesScanned int64
+
+	hooks Hooks
+
+	logger *queryLogger
+
+	attribution *Attribution
+
+	lastQueryID atomic.Value
+
+	// shared is set if identical queries should share an execution. Queries
+	// are only shared with connections opened with the same dsn.
+	shared *queryGroup
+	dsn    string
+
+	// driver is the Driver that opened the connection, if any.
+	driver *Driver
 }
 
 func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
-	if len(args) > 0 {
-		params := make([]interface{}, len(args))
-		for i, _ := range args {
-			params[i] = args[i].Value
+	rows, err := c.query(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if fn := columnsCallback(ctx); fn != nil {
+		fn(rows.(interface{ ColumnInfo() []Column }).ColumnInfo())
+	}
+	return rows, nil
+}
+
+func (c *conn) query(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	params := make([]interface{}, len(args))
+	for i, _ := range args {
+		params[i] = args[i].Value
+	}
+
+	unload := c.resultModeFor(ctx) == ResultModeUnload && presto.IsQuery(query)
+
+	// Only queries are cached, as other statements, such as INSERT or DDL,
+	// must run every time.
+	var cacheKey string
+	if c.cache != nil && !unload && !cacheSkipped(ctx) && presto.IsQuery(query) {
+		db := c.databaseFor(ctx)
+		if catalog := c.catalogFor(ctx); catalog != "" {
+			db = catalog + "." + db
 		}
+		cacheKey = resultCacheKey(db, query, params)
+		if result, ok := c.cache.Get(cacheKey); ok && !result.expired() {
+			return newCachedRows(result), nil
+		}
+	}
 
+	if len(args) > 0 {
 		var err error
 		query, err = presto.ValidateAndFormatSql(query, params...)
 		if err != nil {
@@ -34,8 +101,19 @@ func (c *conn) QueryContext(ctx context.Context, query string, args []driver.Nam
 		}
 	}
 
+	if unload {
+		return c.runUnload(ctx, query)
+	}
+
 	rows, err := c.runQuery(ctx, query)
-	return rows, err
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheKey != "" {
+		return c.cacheRows(cacheKey, rows)
+	}
+	return rows, nil
 }
 
 func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
@@ -43,81 +121,366 @@ func (c *conn) ExecContext(ctx context.Context, query string, args []driver.Name
 		panic("Athena doesn't support prepared statements. Format your own arguments.")
 	}
 
-	_, err := c.runQuery(ctx, query)
-	return nil, err
+	rows, err := c.runQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, rows.Close()
 }
 
-func (c *conn) runQuery(ctx context.Context, query string) (driver.Rows, error) {
-	queryID, err := c.startQuery(query)
+func (c *conn) runQuery(ctx context.Context, query string) (*rows, error) {
+	ctx, execution, err := c.run(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := c.waitOnQuery(ctx, queryID); err != nil {
-		return nil, err
+	// todo add check for ddl queries to not skip header(#10)
+	return c.openRows(ctx, execution, true)
+}
+
+// run runs a query, sharing its execution if DedupQueries is set, and
+// returns its final state, along with the context returned by OnSubmit, which
+// its results must be read with.
+func (c *conn) run(ctx context.Context, query string) (context.Context, *QueryExecution, error) {
+	// Callers sharing an execution with DedupQueries are only told its ID
+	// once it has finished, unless they started it.
+	var once sync.Once
+	var started atomic.Value
+	callback := queryIDCallback(ctx)
+	notify := func(queryID string) {
+		once.Do(func() {
+			started.Store(queryID)
+			c.lastQueryID.Store(queryID)
+			if callback != nil {
+				callback(queryID)
+			}
+		})
 	}
+	ctx = WithQueryIDCallback(ctx, notify)
 
-	return newRows(rowsConfig{
-		Athena:  c.athena,
-		QueryID: queryID,
-		// todo add check for ddl queries to not skip header(#10)
-		SkipHeader: true,
+	hooks := c.hooksFor(ctx)
+	ctx = hooks.submit(ctx, query)
+
+	var execution *QueryExecution
+	var joined bool
+	var err error
+	if c.shared != nil {
+		execution, joined, err = c.shared.run(ctx, c.sharedQueryKey(ctx, query), func(ctx context.Context) (*QueryExecution, error) {
+			return c.executeQuery(ctx, query, true)
+		})
+	} else {
+		execution, err = c.executeQuery(ctx, query, false)
+	}
+
+	// However the caller stopped waiting on the query, even if it was never
+	// started, it's logged and OnComplete is called.
+	queryID, _ := started.Load().(string)
+	if execution != nil {
+		queryID = execution.QueryID
+	}
+	if queryID == "" {
+		c.logger.startFailed(ctx, query, err)
+	} else {
+		c.logger.finished(ctx, queryID, execution, err)
+	}
+	info := c.queryInfo(ctx, queryID, query)
+	info.Joined = joined
+	hooks.completeQuery(ctx, info, execution, err)
+
+	if execution != nil {
+		notify(execution.QueryID)
+		if fn := statsCallback(ctx); fn != nil {
+			fn(newQueryStats(execution))
+		}
+	}
+	return ctx, execution, err
+}
+
+// queryInfo describes a query run with ctx before Athena does.
+func (c *conn) queryInfo(ctx context.Context, queryID, query string) QueryInfo {
+	return QueryInfo{
+		QueryID:   queryID,
+		Query:     query,
+		Database:  c.databaseFor(ctx),
+		WorkGroup: c.workGroupFor(ctx),
+	}
+}
+
+// openRows returns the results of a query that succeeded. If they can't be
+// opened, OnClose is called right away, as the rows won't be closed.
+func (c *conn) openRows(ctx context.Context, execution *QueryExecution, skipHeader bool) (*rows, error) {
+	r, err := newRows(rowsConfig{
+		Athena:        c.athena,
+		QueryID:       execution.QueryID,
+		SkipHeader:    skipHeader,
+		Prefetch:      c.prefetchPages,
+		PageSize:      c.pageSizeFor(ctx),
+		MaxRows:       c.maxRowsFor(ctx),
+		StopAtMaxRows: c.stopAtMaxRows,
+		Context:       ctx,
+		Hooks:         c.hooksFor(ctx),
+		Info:          newQueryInfo(execution),
 	})
+	if err != nil {
+		c.hooksFor(ctx).close(ctx, newQueryInfo(execution), 0)
+		return nil, err
+	}
+	return r, nil
+}
+
+// executeQuery starts a query and waits for it to finish. shared is set if
+// identical queries of other connections wait on it too. The caller logs the
+// outcome and calls OnComplete.
+func (c *conn) executeQuery(ctx context.Context, query string, shared bool) (*QueryExecution, error) {
+	release, err := c.admission.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryID, err := c.startQuery(ctx, query)
+	if err != nil {
+		release(err)
+		return nil, err
+	}
+
+	running.add(c, queryID, shared)
+	defer running.remove(queryID)
+
+	execution, err := c.waitOnQuery(ctx, queryID)
+	release(err)
+	return execution, err
+}
+
+// startAdmittedQuery starts a query once the admission controller allows it,
+// without holding a concurrency slot while it runs. If it can't be started,
+// it's logged and OnComplete is called, as nothing will wait on it.
+func (c *conn) startAdmittedQuery(ctx context.Context, query string) (string, error) {
+	release, err := c.admission.admit(ctx)
+	if err == nil {
+		var queryID string
+		queryID, err = c.startQuery(ctx, query)
+		release(err)
+		if err == nil {
+			return queryID, nil
+		}
+	}
+
+	c.logger.startFailed(ctx, query, err)
+	c.hooksFor(ctx).completeQuery(ctx, c.queryInfo(ctx, "", query), nil, err)
+	return "", err
+}
+
+// databaseFor returns the database a query run with ctx uses.
+func (c *conn) databaseFor(ctx context.Context) string {
+	if db, ok := stringFromContext(ctx, databaseKey); ok {
+		return db
+	}
+	return c.db
+}
+
+// workGroupFor returns the workgroup a query run with ctx runs in, or "" for
+// Athena's default.
+func (c *conn) workGroupFor(ctx context.Context) string {
+	if workGroup, ok := stringFromContext(ctx, workGroupKey); ok {
+		return workGroup
+	}
+	return c.workGroup
+}
+
+// outputLocationFor returns where a query run with ctx writes its results.
+func (c *conn) outputLocationFor(ctx context.Context) string {
+	if location, ok := stringFromContext(ctx, outputLocationKey); ok {
+		return location
+	}
+	return c.OutputLocation
+}
+
+// catalogFor returns the data catalog a query run with ctx uses, or "" for
+// Athena's default.
+func (c *conn) catalogFor(ctx context.Context) string {
+	if catalog, ok := stringFromContext(ctx, catalogKey); ok {
+		return catalog
+	}
+	return c.catalog
+}
+
+// encryptionFor returns how a query run with ctx encrypts its results, or nil
+// if it doesn't.
+func (c *conn) encryptionFor(ctx context.Context) *Encryption {
+	if enc, ok := encryptionFromContext(ctx); ok {
+		return enc
+	}
+	return c.encryption
+}
+
+// pageSizeFor returns how many rows a page of results of a query run with ctx
+// has, or 0 for Athena's default.
+func (c *conn) pageSizeFor(ctx context.Context) int {
+	if size, ok := pageSizeFromContext(ctx); ok {
+		return size
+	}
+	return c.pageSize
+}
+
+// maxRowsFor returns the most rows of results a query run with ctx can read.
+func (c *conn) maxRowsFor(ctx context.Context) int64 {
+	if limit, ok := maxRowsFromContext(ctx); ok {
+		return limit
+	}
+	return c.maxRows
 }
 
 // startQuery starts an Athena query and returns its ID.
-func (c *conn) startQuery(query string) (string, error) {
-	resp, err := c.athena.StartQueryExecution(&athena.StartQueryExecutionInput{
-		QueryString: aws.String(query),
-		QueryExecutionContext: &athena.QueryExecutionContext{
-			Database: aws.String(c.db),
-		},
-		ResultConfiguration: &athena.ResultConfiguration{
-			OutputLocation: aws.String(c.OutputLocation),
-		},
-	})
+func (c *conn) startQuery(ctx context.Context, query string) (string, error) {
+	query = withComment(query, c.queryComment(ctx))
+	input := &StartQueryExecutionInput{
+		Query:               query,
+		Catalog:             c.catalogFor(ctx),
+		Database:            c.databaseFor(ctx),
+		WorkGroup:           c.workGroupFor(ctx),
+		OutputLocation:      c.outputLocationFor(ctx),
+		ExpectedBucketOwner: c.expectedBucketOwner,
+		S3ACLOption:         c.s3ACLOption,
+		Encryption:          c.encryptionFor(ctx),
+	}
+	if input.Encryption != nil {
+		if err := input.Encryption.validate(); err != nil {
+			return "", err
+		}
+	}
+	if token, ok := clientRequestTokenFromContext(ctx); ok {
+		input.ClientRequestToken = token
+	}
+
+	maxAge, ok := resultReuseMaxAgeFromContext(ctx)
+	if !ok {
+		maxAge = c.resultReuseMaxAge
+	}
+	if err := validateResultReuseMaxAge(maxAge); err != nil {
+		return "", err
+	}
+	input.ResultReuseMaxAge = maxAge
+
+	submittedAt := time.Now()
+	resp, err := c.athena.StartQueryExecution(ctx, input)
 	if err != nil {
 		return "", err
 	}
 
-	return *resp.QueryExecutionId, nil
+	if fn := queryIDCallback(ctx); fn != nil {
+		fn(resp.QueryID)
+	}
+
+	info := QueryInfo{
+		QueryID:     resp.QueryID,
+		Query:       query,
+		Database:    input.Database,
+		WorkGroup:   input.WorkGroup,
+		SubmittedAt: submittedAt,
+	}
+	c.logger.started(ctx, info)
+	c.hooksFor(ctx).start(ctx, info)
+
+	return resp.QueryID, nil
 }
 
 // waitOnQuery blocks until a query finishes, returning an error if it failed.
-func (c *conn) waitOnQuery(ctx context.Context, queryID string) error {
+// The query is stopped if ctx is done before it finishes.
+func (c *conn) waitOnQuery(ctx context.Context, queryID string) (*QueryExecution, error) {
+	execution, err := c.pollQuery(ctx, queryID)
+	if err != nil && ctx.Err() != nil {
+		c.stopQuery(queryID)
+		return execution, ctx.Err()
+	}
+
+	return execution, err
+}
+
+// pollQuery blocks until a query finishes, returning its final state and an
+// error if it failed. Unlike waitOnQuery, it leaves the query running if ctx
+// is done first, in which case the last state seen, if any, is returned.
+func (c *conn) pollQuery(ctx context.Context, queryID string) (*QueryExecution, error) {
+	hooks := c.hooksFor(ctx)
+	var execution *QueryExecution
+	var state string
 	for {
-		statusResp, err := c.athena.GetQueryExecutionWithContext(ctx, &athena.GetQueryExecutionInput{
-			QueryExecutionId: aws.String(queryID),
-		})
+		current, err := c.queryExecution(ctx, queryID)
 		if err != nil {
-			return err
+			hooks.pollFailed(ctx, queryID, execution, err)
+			return execution, err
+		}
+		execution = current
+		state = hooks.poll(ctx, execution, state)
+
+		if done, err := queryDone(execution); done {
+			return execution, err
 		}
 
-		switch *statusResp.QueryExecution.Status.State {
-		case athena.QueryExecutionStateCancelled:
-			return context.Canceled
-		case athena.QueryExecutionStateFailed:
-			reason := *statusResp.QueryExecution.Status.StateChangeReason
-			return errors.New(reason)
-		case athena.QueryExecutionStateSucceeded:
-			return nil
-		case athena.QueryExecutionStateQueued:
-		case athena.QueryExecutionStateRunning:
+		if err := c.checkScanBudget(ctx, execution); err != nil {
+			return execution, err
 		}
 
 		select {
 		case <-ctx.Done():
-			c.athena.StopQueryExecution(&athena.StopQueryExecutionInput{
-				QueryExecutionId: aws.String(queryID),
-			})
-
-			return ctx.Err()
+			return execution, ctx.Err()
 		case <-time.After(c.pollFrequency):
 			continue
 		}
 	}
 }
 
+// queryDone reports whether a query has finished and, if so, the error it failed with.
+func queryDone(execution *QueryExecution) (bool, error) {
+	switch execution.Status.State {
+	case QueryStateCancelled:
+		return true, context.Canceled
+	case QueryStateFailed:
+		return true, &QueryError{
+			QueryID: execution.QueryID,
+			Status:  newQueryStatus(execution),
+		}
+	case QueryStateSucceeded:
+		return true, nil
+	case QueryStateQueued:
+	case QueryStateRunning:
+	}
+
+	return false, nil
+}
+
+// queryExecution fetches the current state of a query.
+func (c *conn) queryExecution(ctx context.Context, queryID string) (*QueryExecution, error) {
+	return c.athena.GetQueryExecution(ctx, &GetQueryExecutionInput{QueryID: queryID})
+}
+
+// stopQuery asks Athena to cancel a query. It's best effort, so errors are ignored.
+func (c *conn) stopQuery(queryID string) {
+	c.athena.StopQueryExecution(context.Background(), &StopQueryExecutionInput{QueryID: queryID})
+}
+
+// LastQueryID returns the ID of the last query the connection ran, or ""
+// if it hasn't run any. It's reached with sql.Conn.Raw(), see QueryIDer.
+func (c *conn) LastQueryID() string {
+	queryID, _ := c.lastQueryID.Load().(string)
+	return queryID
+}
+
+// QueryIDer is implemented by the driver's connections, so that the ID of
+// the last query run on a sql.Conn can be found with:
+//
+//	conn.Raw(func(driverConn interface{}) error {
+//		queryID = driverConn.(athena.QueryIDer).LastQueryID()
+//		return nil
+//	})
+//
+// Queries answered from Config.Cache don't run, so they don't change it.
+type QueryIDer interface {
+	LastQueryID() string
+}
+
+var _ QueryIDer = (*conn)(nil)
+
 func (c *conn) Prepare(query string) (driver.Stmt, error) {
 	panic("Athena doesn't support prepared statements")
 }
@@ -126,8 +489,12 @@ func (c *conn) Begin() (driver.Tx, error) {
 	panic("Athena doesn't support transactions")
 }
 
+// Close stops the queries the connection is waiting on, if any. Queries it
+// shares with other connections are left to stop once nobody waits on them.
 func (c *conn) Close() error {
-	return nil
+	return running.stop(context.Background(), func(q trackedQuery) bool {
+		return q.conn == c && !q.shared
+	})
 }
 
 var _ driver.QueryerContext = (*conn)(nil)
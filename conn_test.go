@@ -0,0 +1,156 @@
+package athena
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConn_ResultReuse(t *testing.T) {
+	mock := &mockAsyncAthenaClient{
+		states: map[string][]string{
+			"select": {QueryStateSucceeded},
+		},
+		statistics: QueryExecutionStatistics{
+			TotalExecutionTime: 1500 * time.Millisecond,
+			ResultReused:       true,
+		},
+	}
+	c := &conn{
+		athena:            mock,
+		pollFrequency:     time.Millisecond,
+		resultReuseMaxAge: time.Hour,
+	}
+
+	var stats QueryStats
+	ctx := WithStatsCallback(context.Background(), func(s QueryStats) {
+		stats = s
+	})
+	_, err := c.QueryContext(ctx, "select", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Hour, mock.started[0].ResultReuseMaxAge)
+	assert.Equal(t, QueryStats{
+		QueryID:            "select",
+		TotalExecutionTime: 1500 * time.Millisecond,
+		ResultReused:       true,
+	}, stats)
+
+	_, err = c.QueryContext(WithResultReuseMaxAge(context.Background(), 0), "select", nil)
+	require.NoError(t, err)
+	assert.Zero(t, mock.started[1].ResultReuseMaxAge)
+
+	_, err = c.QueryContext(WithResultReuseMaxAge(context.Background(), time.Second), "select", nil)
+	assert.Error(t, err)
+}
+
+func TestConn_QueryID(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"select": {QueryStateRunning, QueryStateSucceeded},
+		"failed": {QueryStateFailed},
+	}}
+	c := &conn{athena: mock, pollFrequency: time.Millisecond}
+	assert.Empty(t, c.LastQueryID())
+
+	var ids []string
+	ctx := WithQueryIDCallback(context.Background(), func(id string) {
+		ids = append(ids, id)
+	})
+	r, err := c.QueryContext(ctx, "select", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "select", r.(*rows).QueryID())
+	assert.Equal(t, "select", c.LastQueryID())
+
+	_, err = c.QueryContext(ctx, "failed", nil)
+	var queryErr *QueryError
+	require.ErrorAs(t, err, &queryErr)
+	assert.Equal(t, "failed", queryErr.QueryID)
+	assert.Equal(t, QueryStateFailed, queryErr.Status.State)
+	assert.EqualError(t, err, "reason")
+	assert.Equal(t, "failed", c.LastQueryID())
+
+	assert.Equal(t, []string{"select", "failed"}, ids)
+}
+
+func TestConn_ContextOverrides(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"select": {QueryStateSucceeded},
+	}}
+	c := &conn{
+		athena:         mock,
+		db:             "db",
+		catalog:        "dynamodb",
+		workGroup:      "primary",
+		OutputLocation: "s3://results",
+		pollFrequency:  time.Millisecond,
+
+		encryption:          &Encryption{Option: EncryptionSSES3},
+		expectedBucketOwner: "123456789012",
+		s3ACLOption:         S3ACLBucketOwnerFullControl,
+	}
+
+	_, err := c.QueryContext(context.Background(), "select", nil)
+	require.NoError(t, err)
+
+	ctx := WithDatabase(context.Background(), "other_db")
+	ctx = WithWorkGroup(ctx, "exports")
+	ctx = WithOutputLocation(ctx, "s3://sensitive")
+	ctx = WithCatalog(ctx, "hive")
+	ctx = WithEncryption(ctx, &Encryption{Option: EncryptionSSEKMS, KMSKey: "key"})
+	_, err = c.QueryContext(ctx, "select", nil)
+	require.NoError(t, err)
+
+	_, err = c.QueryContext(WithEncryption(context.Background(), &Encryption{Option: EncryptionSSEKMS}), "select", nil)
+	assert.EqualError(t, err, "encryption option SSE_KMS requires a KMS key")
+
+	require.Len(t, mock.started, 2)
+	assert.Equal(t, &StartQueryExecutionInput{
+		Query:               "select",
+		Catalog:             "dynamodb",
+		Database:            "db",
+		WorkGroup:           "primary",
+		OutputLocation:      "s3://results",
+		ExpectedBucketOwner: "123456789012",
+		S3ACLOption:         S3ACLBucketOwnerFullControl,
+		Encryption:          &Encryption{Option: EncryptionSSES3},
+	}, mock.started[0])
+	assert.Equal(t, &StartQueryExecutionInput{
+		Query:               "select",
+		Catalog:             "hive",
+		Database:            "other_db",
+		WorkGroup:           "exports",
+		OutputLocation:      "s3://sensitive",
+		ExpectedBucketOwner: "123456789012",
+		S3ACLOption:         S3ACLBucketOwnerFullControl,
+		Encryption:          &Encryption{Option: EncryptionSSEKMS, KMSKey: "key"},
+	}, mock.started[1])
+}
+
+func TestConn_ColumnsCallback(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"select": {QueryStateSucceeded},
+	}}
+	c := &conn{
+		athena:        mock,
+		pollFrequency: time.Millisecond,
+		cache:         NewLRUCache(10),
+		cacheTTL:      time.Minute,
+	}
+
+	want := []Column{
+		{Name: "first_name", Type: "varchar", CatalogName: "hive"},
+		{Name: "last_name", Type: "varchar", CatalogName: "hive"},
+	}
+	for _, cached := range []bool{false, true} {
+		var columns []Column
+		ctx := WithColumnsCallback(context.Background(), func(c []Column) { columns = c })
+		r, err := c.QueryContext(ctx, "select", nil)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		assert.Equal(t, want, columns, "cached: %v", cached)
+	}
+	assert.Len(t, mock.started, 1)
+}
@@ -0,0 +1,217 @@
+package athena
+
+import (
+	"context"
+	"time"
+)
+
+type contextKey int
+
+const (
+	skipCacheKey contextKey = iota
+	resultReuseMaxAgeKey
+	statsCallbackKey
+	maxBytesScannedKey
+	hooksKey
+	attributionKey
+	clientRequestTokenKey
+	queryIDCallbackKey
+	databaseKey
+	workGroupKey
+	outputLocationKey
+	catalogKey
+	encryptionKey
+	pageSizeKey
+	maxRowsKey
+	resultModeKey
+	columnsCallbackKey
+)
+
+// WithoutCache returns a context that makes queries bypass Config.Cache.
+// Their results are neither read from nor written to the cache.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipCacheKey, true)
+}
+
+func cacheSkipped(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipCacheKey).(bool)
+	return skip
+}
+
+// WithResultReuseMaxAge returns a context that overrides Config.ResultReuseMaxAge
+// for queries run with it. A maxAge of 0 disables result reuse.
+func WithResultReuseMaxAge(ctx context.Context, maxAge time.Duration) context.Context {
+	return context.WithValue(ctx, resultReuseMaxAgeKey, maxAge)
+}
+
+func resultReuseMaxAgeFromContext(ctx context.Context) (time.Duration, bool) {
+	maxAge, ok := ctx.Value(resultReuseMaxAgeKey).(time.Duration)
+	return maxAge, ok
+}
+
+// WithStatsCallback returns a context that makes queries run with it call fn
+// with their statistics once they finish, whether they succeeded or not.
+func WithStatsCallback(ctx context.Context, fn func(QueryStats)) context.Context {
+	return context.WithValue(ctx, statsCallbackKey, fn)
+}
+
+func statsCallback(ctx context.Context) func(QueryStats) {
+	fn, _ := ctx.Value(statsCallbackKey).(func(QueryStats))
+	return fn
+}
+
+// WithMaxBytesScanned returns a context that overrides Config.MaxBytesScanned
+// for queries run with it. A limit of 0 disables it.
+func WithMaxBytesScanned(ctx context.Context, limit int64) context.Context {
+	return context.WithValue(ctx, maxBytesScannedKey, limit)
+}
+
+func maxBytesScannedFromContext(ctx context.Context) (int64, bool) {
+	limit, ok := ctx.Value(maxBytesScannedKey).(int64)
+	return limit, ok
+}
+
+// WithHooks returns a context that replaces Config.Hooks for queries run with it.
+// All of them are replaced, including those installed by packages such as
+// athenaotel and athenaprom, so to keep them, compose them with the new ones:
+//
+//	ctx = athena.WithHooks(ctx, athena.ComposeHooks(configured, hooks))
+func WithHooks(ctx context.Context, hooks Hooks) context.Context {
+	return context.WithValue(ctx, hooksKey, hooks)
+}
+
+func hooksFromContext(ctx context.Context) (Hooks, bool) {
+	hooks, ok := ctx.Value(hooksKey).(Hooks)
+	return hooks, ok
+}
+
+type attributionPair struct {
+	key, value string
+}
+
+// WithAttribution returns a context that adds key=value to the comment
+// prepended to queries run with it, after the pairs added by its parents.
+// See Attribution.
+func WithAttribution(ctx context.Context, key, value string) context.Context {
+	parent := attributionFromContext(ctx)
+	pairs := make([]attributionPair, len(parent), len(parent)+1)
+	copy(pairs, parent)
+	return context.WithValue(ctx, attributionKey, append(pairs, attributionPair{key, value}))
+}
+
+func attributionFromContext(ctx context.Context) []attributionPair {
+	pairs, _ := ctx.Value(attributionKey).([]attributionPair)
+	return pairs
+}
+
+// WithClientRequestToken returns a context that makes queries run with it
+// start with token as their ClientRequestToken, e.g. to tie them to the
+// request that issued them. Athena rejects a token that was already used for
+// a different query, and doesn't start a query again for a token it has seen,
+// so it must be unique to each query. It must be 32 to 128 characters long.
+func WithClientRequestToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, clientRequestTokenKey, token)
+}
+
+func clientRequestTokenFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(clientRequestTokenKey).(string)
+	return token, ok
+}
+
+// WithQueryIDCallback returns a context that makes queries run with it call
+// fn with their execution ID as soon as Athena has accepted them.
+func WithQueryIDCallback(ctx context.Context, fn func(queryID string)) context.Context {
+	return context.WithValue(ctx, queryIDCallbackKey, fn)
+}
+
+func queryIDCallback(ctx context.Context) func(string) {
+	fn, _ := ctx.Value(queryIDCallbackKey).(func(string))
+	return fn
+}
+
+// WithColumnsCallback returns a context that makes queries run with it
+// through database/sql call fn with the columns of their results, including
+// the catalog, schema and table each comes from, which sql.ColumnType doesn't
+// tell. Results.ColumnInfo() tells the same for Client's queries.
+func WithColumnsCallback(ctx context.Context, fn func([]Column)) context.Context {
+	return context.WithValue(ctx, columnsCallbackKey, fn)
+}
+
+func columnsCallback(ctx context.Context) func([]Column) {
+	fn, _ := ctx.Value(columnsCallbackKey).(func([]Column))
+	return fn
+}
+
+// WithDatabase returns a context that makes queries run with it use db
+// instead of Config.Database.
+func WithDatabase(ctx context.Context, db string) context.Context {
+	return context.WithValue(ctx, databaseKey, db)
+}
+
+// WithWorkGroup returns a context that makes queries run with it use
+// workGroup instead of Config.WorkGroup.
+func WithWorkGroup(ctx context.Context, workGroup string) context.Context {
+	return context.WithValue(ctx, workGroupKey, workGroup)
+}
+
+// WithOutputLocation returns a context that makes queries run with it write
+// their results to location, an S3 URL, instead of Config.OutputLocation.
+func WithOutputLocation(ctx context.Context, location string) context.Context {
+	return context.WithValue(ctx, outputLocationKey, location)
+}
+
+// WithCatalog returns a context that makes queries run with it use the data
+// catalog named catalog instead of Config.Catalog.
+func WithCatalog(ctx context.Context, catalog string) context.Context {
+	return context.WithValue(ctx, catalogKey, catalog)
+}
+
+// WithEncryption returns a context that makes queries run with it encrypt
+// their results as set by enc instead of Config.Encryption. A nil enc leaves
+// them unencrypted, unless their workgroup requires otherwise.
+func WithEncryption(ctx context.Context, enc *Encryption) context.Context {
+	return context.WithValue(ctx, encryptionKey, enc)
+}
+
+// WithPageSize returns a context that overrides Config.PageSize for queries
+// run with it.
+func WithPageSize(ctx context.Context, size int) context.Context {
+	return context.WithValue(ctx, pageSizeKey, size)
+}
+
+func pageSizeFromContext(ctx context.Context) (int, bool) {
+	size, ok := ctx.Value(pageSizeKey).(int)
+	return size, ok
+}
+
+// WithMaxRows returns a context that overrides Config.MaxRows for queries run
+// with it. A limit of 0 disables it.
+func WithMaxRows(ctx context.Context, limit int64) context.Context {
+	return context.WithValue(ctx, maxRowsKey, limit)
+}
+
+func maxRowsFromContext(ctx context.Context) (int64, bool) {
+	limit, ok := ctx.Value(maxRowsKey).(int64)
+	return limit, ok
+}
+
+// WithResultMode returns a context that makes queries run with it get their
+// results as mode says instead of Config.ResultMode.
+func WithResultMode(ctx context.Context, mode ResultMode) context.Context {
+	return context.WithValue(ctx, resultModeKey, mode)
+}
+
+func resultModeFromContext(ctx context.Context) (ResultMode, bool) {
+	mode, ok := ctx.Value(resultModeKey).(ResultMode)
+	return mode, ok
+}
+
+func stringFromContext(ctx context.Context, key contextKey) (string, bool) {
+	s, ok := ctx.Value(key).(string)
+	return s, ok
+}
+
+func encryptionFromContext(ctx context.Context) (*Encryption, bool) {
+	enc, ok := ctx.Value(encryptionKey).(*Encryption)
+	return enc, ok
+}
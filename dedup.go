@@ -0,0 +1,93 @@
+package athena
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// queryGroup collapses identical queries that are in flight at the same
+// time into a single Athena execution. Its zero value is ready to use.
+type queryGroup struct {
+	mu      sync.Mutex
+	queries map[string]*sharedQuery
+}
+
+type sharedQuery struct {
+	done      chan struct{}
+	execution *QueryExecution
+	err       error
+
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// run calls execute, unless a query with the same key is already running,
+// in which case it waits for that one instead and joined is set.
+//
+// execute runs detached from ctx, so that one caller giving up doesn't stop
+// the query for everyone else. It's only cancelled once all callers waiting
+// on it have given up.
+func (g *queryGroup) run(ctx context.Context, key string, execute func(context.Context) (*QueryExecution, error)) (execution *QueryExecution, joined bool, err error) {
+	g.mu.Lock()
+	if g.queries == nil {
+		g.queries = make(map[string]*sharedQuery)
+	}
+
+	q, ok := g.queries[key]
+	if !ok {
+		qctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		q = &sharedQuery{done: make(chan struct{}), cancel: cancel}
+		g.queries[key] = q
+
+		go func() {
+			defer cancel()
+			q.execution, q.err = execute(qctx)
+
+			g.mu.Lock()
+			g.forget(key, q)
+			g.mu.Unlock()
+			close(q.done)
+		}()
+	}
+	q.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return q.execution, ok, q.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		q.waiters--
+		if q.waiters == 0 {
+			// Nobody is waiting on it anymore, so don't let new callers join it.
+			g.forget(key, q)
+			q.cancel()
+		}
+		g.mu.Unlock()
+		return nil, ok, ctx.Err()
+	}
+}
+
+// forget removes q from the group, unless it has already been replaced.
+func (g *queryGroup) forget(key string, q *sharedQuery) {
+	if g.queries[key] == q {
+		delete(g.queries, key)
+	}
+}
+
+// sharedQueryKey identifies a query by everything that affects where and how
+// it runs, so that only queries with the same results share an execution.
+func (c *conn) sharedQueryKey(ctx context.Context, query string) string {
+	key := []string{
+		c.dsn,
+		c.databaseFor(ctx),
+		c.workGroupFor(ctx),
+		c.catalogFor(ctx),
+		c.outputLocationFor(ctx),
+	}
+	if enc := c.encryptionFor(ctx); enc != nil {
+		key = append(key, enc.Option, enc.KMSKey)
+	}
+	return strings.Join(append(key, query), "\x00")
+}
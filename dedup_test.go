@@ -0,0 +1,91 @@
+package athena
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryGroup_Run(t *testing.T) {
+	var g queryGroup
+	var executions, joiners int32
+	release := make(chan struct{})
+	execute := func(ctx context.Context) (*QueryExecution, error) {
+		atomic.AddInt32(&executions, 1)
+		<-release
+		return &QueryExecution{QueryID: "id"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			execution, joined, err := g.run(context.Background(), "key", execute)
+			assert.NoError(t, err)
+			if joined {
+				atomic.AddInt32(&joiners, 1)
+			}
+			assert.Equal(t, "id", execution.QueryID)
+		}()
+	}
+
+	// Give every caller a chance to join before the query finishes.
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), executions)
+	assert.Equal(t, int32(9), joiners)
+
+	_, joined, err := g.run(context.Background(), "key", execute)
+	require.NoError(t, err)
+	assert.False(t, joined)
+	assert.Equal(t, int32(2), executions, "finished queries shouldn't be shared")
+}
+
+func TestQueryGroup_Run_Cancel(t *testing.T) {
+	var g queryGroup
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	execute := func(ctx context.Context) (*QueryExecution, error) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, _, err := g.run(ctx1, "key", execute)
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, _, err := g.run(ctx2, "key", execute)
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel1()
+	assert.Equal(t, context.Canceled, <-errs)
+	select {
+	case <-cancelled:
+		t.Fatal("query cancelled while a caller is still waiting on it")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	cancel2()
+	assert.Equal(t, context.Canceled, <-errs)
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("query not cancelled once every caller gave up")
+	}
+}
@@ -5,13 +5,15 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/athena"
 )
 
 var (
@@ -22,6 +24,10 @@ var (
 // Driver is a sql.Driver. It's intended for db/sql.Open().
 type Driver struct {
 	cfg *Config
+
+	// shared holds the queries its connections are running, when
+	// Config.DedupQueries is set.
+	shared queryGroup
 }
 
 // NewDriver allows you to register your own driver with `sql.Register`.
@@ -30,7 +36,7 @@ type Driver struct {
 //
 // Generally, sql.Open() or athena.Open() should suffice.
 func NewDriver(cfg *Config) *Driver {
-	return &Driver{cfg}
+	return &Driver{cfg: cfg}
 }
 
 func init() {
@@ -50,17 +56,92 @@ func init() {
 // "s3://bucket/and/so/forth". In the AWS UI, this defaults to
 // "s3://aws-athena-query-results-<ACCOUNTID>-<REGION>", but the driver requires it.
 //
+// - `encryption` and `kms_key` (optional)
+// How Athena encrypts query results: SSE_S3, SSE_KMS or CSE_KMS. The latter
+// two require kms_key, the ARN or ID of the KMS key to use.
+//
+// - `expected_bucket_owner` (optional)
+// The AWS account ID that must own the output_location bucket.
+//
+// - `s3_acl_option` (optional)
+// The canned ACL results are written with. Only BUCKET_OWNER_FULL_CONTROL
+// is supported.
+//
+// - `workgroup` (optional)
+// The Athena workgroup queries run in. Athena uses "primary" if it's not set.
+//
+// - `catalog` (optional)
+// The data catalog queries use. Athena uses "AwsDataCatalog" if it's not set.
+//
 // - `poll_frequency` (optional)
 // Athena's API requires polling to retrieve query results. This is the frequency at
 // which the driver will poll for results. It should be a time/Duration.String().
 // A completely arbitrary default of "5s" was chosen.
 //
+// - `prefetch_pages` (optional)
+// How many pages of results to fetch in the background ahead of the one
+// being read. None are by default. See Config.PrefetchPages.
+//
+// - `result_mode` and `unload_location` (optional)
+// "unload" makes Athena UNLOAD the results of queries to Parquet files under
+// unload_location, which defaults to output_location + "/unload". See
+// ResultModeUnload.
+//
+// - `page_size` (optional)
+// How many rows to fetch per page of results, at most 1000, the default.
+//
+// - `max_rows` and `stop_at_max_rows` (optional)
+// The most rows of results that can be read, and whether reading more ends
+// the rows ("true") instead of failing. See Config.MaxRows.
+//
+// - `result_reuse_max_age` (optional)
+// Lets Athena answer a query with the results of an identical one that ran
+// within this long, instead of running it again. It should be a
+// time/Duration.String() of at least a minute and at most 7 days.
+// Reuse is disabled by default.
+//
+// - `dedup_queries` (optional)
+// If "true", identical queries that run at the same time on the same sql.DB
+// share a single Athena execution. See Config.DedupQueries.
+//
+// - `max_bytes_scanned` (optional)
+// Stops queries once they've scanned more than this many bytes.
+// See Config.MaxBytesScanned.
+//
+// - `app` (optional)
+// Prepends a comment naming the application to every query.
+// See Config.Attribution.
+//
 // - `region` (optional)
 // Override AWS region. Useful if it is not set with environment variable.
 //
-// Credentials must be accessible via the SDK's Default Credential Provider Chain.
-// For more advanced AWS credentials/session/config management, please supply
-// a custom AWS session directly via `athena.Open()`.
+// - `profile` (optional)
+// The shared config profile to get credentials and settings from.
+//
+// - `role_arn`, `external_id` and `role_session_name` (optional)
+// A role to assume for queries, with the external ID and session name to
+// assume it with, if any.
+//
+// - `endpoint` (optional)
+// Override Athena's endpoint URL, e.g. to use a local stand-in.
+//
+// - `max_retries` (optional)
+// The most times a failed AWS request is retried. The SDK's default is 3.
+//
+// - `http_timeout` (optional)
+// The timeout of AWS requests, as a time/Duration.String(). There's none by
+// default.
+//
+// db, output_location, workgroup, catalog and poll_frequency fall back to the
+// ATHENA_DATABASE, ATHENA_OUTPUT_LOCATION, ATHENA_WORKGROUP, ATHENA_CATALOG
+// and ATHENA_POLL_FREQUENCY environment variables when they're missing from
+// the DSN or empty, so sql.Open("athena", "") works if they're set. The DSN
+// takes precedence over the environment.
+//
+// Credentials must be accessible via the SDK's Default Credential Provider
+// Chain, using profile if it's set, unless role_arn is set. For more advanced
+// AWS credentials/session/config management, please supply a custom AWS
+// session directly via `athena.Open()`.
 func (d *Driver) Open(connStr string) (driver.Conn, error) {
 	cfg := d.cfg
 	if cfg == nil {
@@ -71,32 +152,73 @@ func (d *Driver) Open(connStr string) (driver.Conn, error) {
 		}
 	}
 
-	if cfg.PollFrequency == 0 {
-		cfg.PollFrequency = 5 * time.Second
+	c := newConn(cfg)
+	c.driver = d
+	if cfg.DedupQueries {
+		c.shared = &d.shared
+		c.dsn = connStr
+	}
+	return c, nil
+}
+
+func newConn(cfg *Config) *conn {
+	pollFrequency := cfg.PollFrequency
+	if pollFrequency == 0 {
+		pollFrequency = 5 * time.Second
+	}
+
+	cacheTTL := cfg.CacheTTL
+	if cacheTTL == 0 {
+		cacheTTL = 5 * time.Minute
+	}
+
+	logger := newQueryLogger(cfg)
+	api := cfg.API
+	if api == nil {
+		api = newSessionAPI(cfg.Session, cfg.Endpoint, logger)
+	}
+	s3API := cfg.S3
+	if s3API == nil && cfg.Session != nil {
+		s3API = newSessionS3API(cfg.Session)
 	}
 
 	return &conn{
-		athena:         athena.New(cfg.Session),
+		athena:         api,
 		db:             cfg.Database,
+		catalog:        cfg.Catalog,
+		workGroup:      cfg.WorkGroup,
 		OutputLocation: cfg.OutputLocation,
-		pollFrequency:  cfg.PollFrequency,
-	}, nil
+		pollFrequency:  pollFrequency,
+		prefetchPages:  cfg.PrefetchPages,
+		pageSize:       cfg.PageSize,
+		maxRows:        cfg.MaxRows,
+		stopAtMaxRows:  cfg.StopAtMaxRows,
+		resultMode:     cfg.ResultMode,
+		unloadLocation: cfg.UnloadLocation,
+		s3:             s3API,
+		cache:          cfg.Cache,
+		cacheTTL:       cacheTTL,
+		cacheMaxRows:   cfg.CacheMaxRows,
+
+		encryption:          cfg.Encryption,
+		expectedBucketOwner: cfg.ExpectedBucketOwner,
+		s3ACLOption:         cfg.S3ACLOption,
+
+		resultReuseMaxAge: cfg.ResultReuseMaxAge,
+		admission:         cfg.Admission,
+		maxBytesScanned:   cfg.MaxBytesScanned,
+		hooks:             cfg.Hooks,
+		logger:            logger,
+		attribution:       cfg.Attribution,
+	}
 }
 
 // Open is a more robust version of `db.Open`, as it accepts a raw aws.Session.
 // This is useful if you have a complex AWS session since the driver doesn't
 // currently attempt to serialize all options into a string.
 func Open(cfg Config) (*sql.DB, error) {
-	if cfg.Database == "" {
-		return nil, errors.New("db is required")
-	}
-
-	if cfg.OutputLocation == "" {
-		return nil, errors.New("s3_staging_url is required")
-	}
-
-	if cfg.Session == nil {
-		return nil, errors.New("session is required")
+	if err := cfg.prepare(); err != nil {
+		return nil, err
 	}
 
 	// This hack was copied from jackc/pgx. Sorry :(
@@ -106,7 +228,7 @@ func Open(cfg Config) (*sql.DB, error) {
 	name := fmt.Sprintf("athena-%d", openFromSessionCount)
 	openFromSessionMutex.Unlock()
 
-	sql.Register(name, &Driver{&cfg})
+	sql.Register(name, &Driver{cfg: &cfg})
 	return sql.Open(name, "")
 }
 
@@ -114,9 +236,203 @@ func Open(cfg Config) (*sql.DB, error) {
 type Config struct {
 	Session        *session.Session
 	Database       string
+	WorkGroup      string
 	OutputLocation string
 
+	// API, if set, is used to call Athena instead of a client made from
+	// Session, e.g. to use the AWS SDK for Go v2 with the athenav2 package.
+	// Session and the settings below are ignored then.
+	API AthenaAPI
+
+	// S3, if set, is used to read and delete the results of queries run with
+	// ResultModeUnload instead of a client made from Session.
+	S3 S3API
+
+	// Region, Profile, RoleARN, ExternalID, RoleSessionName, MaxRetries and
+	// HTTPTimeout configure the AWS session created when Session isn't set,
+	// like the DSN keys of the same names. They're ignored otherwise.
+	// MaxRetries uses the SDK's default if it's nil.
+	Region          string
+	Profile         string
+	RoleARN         string
+	ExternalID      string
+	RoleSessionName string
+	MaxRetries      *int
+	HTTPTimeout     time.Duration
+
+	// Endpoint, if set, overrides the endpoint URL of the Athena client made
+	// from Session. S3 and STS, used to assume RoleARN, keep their own.
+	Endpoint string
+
+	// Encryption, if set, makes Athena encrypt query results in
+	// OutputLocation. Use athena.WithEncryption() to override it for a single
+	// query.
+	Encryption *Encryption
+
+	// ExpectedBucketOwner, if set, is the AWS account ID that must own
+	// OutputLocation's bucket, or Athena won't write results to it.
+	ExpectedBucketOwner string
+
+	// S3ACLOption, if set, is the canned ACL results are written with. Only
+	// S3ACLBucketOwnerFullControl is supported, which gives the
+	// bucket's owner full control of results written by other accounts.
+	S3ACLOption string
+
+	// Catalog is the data catalog queries use, e.g. a federated one backed by
+	// a Lambda function. It defaults to Athena's own, "AwsDataCatalog". The
+	// catalog each column comes from is reported by Results.ColumnInfo(), or
+	// to database/sql users with athena.WithColumnsCallback().
+	Catalog string
+
 	PollFrequency time.Duration
+
+	// PrefetchPages, if set, is how many pages of results are fetched in the
+	// background ahead of the one being read, so that reading doesn't wait
+	// for Athena between pages.
+	PrefetchPages int
+
+	// PageSize, if set, is how many rows are fetched per page of results, at
+	// most 1000, Athena's default. Use athena.WithPageSize() to override it
+	// for a single query.
+	PageSize int
+
+	// MaxRows, if set, limits how many rows of results can be read. Reading
+	// past it fails with an *ErrRowLimitExceeded, or if StopAtMaxRows is
+	// set, ends the rows quietly. Either way, no further pages are fetched.
+	// Use athena.WithMaxRows() to override it for a single query.
+	MaxRows       int64
+	StopAtMaxRows bool
+
+	// ResultMode is how the results of queries are read. It defaults to
+	// ResultModeAPI. Use athena.WithResultMode() to override it for a single
+	// query.
+	ResultMode ResultMode
+
+	// UnloadLocation is the S3 prefix under which queries run with
+	// ResultModeUnload write their results, each under a prefix of its own.
+	// It defaults to OutputLocation + "/unload".
+	UnloadLocation string
+
+	// Cache, if set, stores the results of db.Query() calls so that identical
+	// queries within CacheTTL are answered without running them on Athena.
+	// Only queries (SELECT, WITH, VALUES and TABLE) are cached; other
+	// statements always run.
+	// Queries are identified by their SQL, ignoring whitespace and comments,
+	// their parameters and the database. Use athena.WithoutCache() to bypass
+	// it for a single query. CacheTTL defaults to 5 minutes.
+	Cache    ResultCache
+	CacheTTL time.Duration
+
+	// CacheMaxRows is the most rows a result can have to be cached. It
+	// defaults to 10,000. Results are read into memory before being cached,
+	// so larger ones are returned as they're read instead, and not cached.
+	CacheMaxRows int
+
+	// ResultReuseMaxAge, if set, lets Athena answer a query with the results
+	// of an identical one that ran within this long. It's rounded down to the
+	// minute and must be between a minute and 7 days. Use
+	// athena.WithResultReuseMaxAge() to override it for a single query.
+	ResultReuseMaxAge time.Duration
+
+	// DedupQueries makes identical queries (same SQL, database and workgroup)
+	// that are in flight at the same time share a single Athena execution.
+	// Each caller still gets its own rows. The execution is only stopped once
+	// every caller waiting on it has given up. Hooks are only called with the
+	// context of the caller that started the execution, except for OnPage.
+	DedupQueries bool
+
+	// Admission, if set, limits the rate and concurrency at which queries are
+	// started. See AdmissionController.
+	Admission *AdmissionController
+
+	// MaxBytesScanned, if set, stops queries as soon as they've scanned more
+	// than this many bytes, failing them with an *ErrScanBudgetExceeded.
+	// Athena only reports progress when polled, so a query may overshoot by
+	// however much it scans in PollFrequency. Use athena.WithMaxBytesScanned()
+	// to override it for a single query.
+	MaxBytesScanned int64
+
+	// Hooks are called as queries progress. See Hooks.
+	Hooks Hooks
+
+	// Logger, if set, is logged to as queries progress. Messages below
+	// LogLevel are dropped; it defaults to slog.LevelInfo, which leaves out
+	// queries being started. Queries are logged with every literal redacted,
+	// as that's where their parameters end up, unless LogParameters is set.
+	Logger        Logger
+	LogLevel      slog.Level
+	LogParameters bool
+
+	// Attribution, if set, prepends a comment saying where each query came
+	// from. See Attribution.
+	Attribution *Attribution
+}
+
+// prepare validates c and creates its Session if it's not set.
+func (c *Config) prepare() error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	if c.Session == nil && c.API == nil {
+		sess, err := c.newSession()
+		if err != nil {
+			return err
+		}
+		c.Session = sess
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	if c.Database == "" {
+		return errors.New("db is required")
+	}
+
+	if c.OutputLocation == "" {
+		return errors.New("s3_staging_url is required")
+	}
+
+	if err := validatePageSize(c.PageSize); err != nil {
+		return err
+	}
+
+	if err := validateResultMode(c.ResultMode); err != nil {
+		return err
+	}
+
+	if c.MaxRows < 0 {
+		return fmt.Errorf("max rows must not be negative, not %d", c.MaxRows)
+	}
+
+	if c.PrefetchPages < 0 {
+		return fmt.Errorf("prefetch pages must not be negative, not %d", c.PrefetchPages)
+	}
+
+	if c.MaxRetries != nil && *c.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative, not %d", *c.MaxRetries)
+	}
+
+	if err := validateResultReuseMaxAge(c.ResultReuseMaxAge); err != nil {
+		return err
+	}
+
+	return c.validateResultConfiguration()
+}
+
+// validateResultConfiguration checks the settings for how results are written.
+func (c *Config) validateResultConfiguration() error {
+	if c.Encryption != nil {
+		if err := c.Encryption.validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := validateExpectedBucketOwner(c.ExpectedBucketOwner); err != nil {
+		return err
+	}
+
+	return validateS3ACLOption(c.S3ACLOption)
 }
 
 func configFromConnectionString(connStr string) (*Config, error) {
@@ -125,18 +441,49 @@ func configFromConnectionString(connStr string) (*Config, error) {
 		return nil, err
 	}
 
+	withEnvFallbacks(args)
+	var missing []string
+	for _, f := range envFallbacks {
+		if f.required && args.Get(f.key) == "" {
+			missing = append(missing, fmt.Sprintf("%s (or %s)", f.key, f.env))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required settings: %s", strings.Join(missing, ", "))
+	}
+
 	var cfg Config
 
-	var acfg []*aws.Config
-	if region := args.Get("region"); region != "" {
-		acfg = append(acfg, &aws.Config{Region: aws.String(region)})
+	cfg.Region = args.Get("region")
+	cfg.Profile = args.Get("profile")
+	cfg.RoleARN = args.Get("role_arn")
+	cfg.ExternalID = args.Get("external_id")
+	cfg.RoleSessionName = args.Get("role_session_name")
+	cfg.Endpoint = args.Get("endpoint")
+
+	if retriesStr := args.Get("max_retries"); retriesStr != "" {
+		retries, err := strconv.Atoi(retriesStr)
+		if err != nil || retries < 0 {
+			return nil, fmt.Errorf("invalid max_retries parameter: %s", retriesStr)
+		}
+		cfg.MaxRetries = &retries
+	}
+
+	if timeoutStr := args.Get("http_timeout"); timeoutStr != "" {
+		cfg.HTTPTimeout, err = time.ParseDuration(timeoutStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http_timeout parameter: %s", timeoutStr)
+		}
 	}
-	cfg.Session, err = session.NewSession(acfg...)
+
+	cfg.Session, err = cfg.newSession()
 	if err != nil {
 		return nil, err
 	}
 
 	cfg.Database = args.Get("db")
+	cfg.WorkGroup = args.Get("workgroup")
+	cfg.Catalog = args.Get("catalog")
 	cfg.OutputLocation = args.Get("output_location")
 
 	frequencyStr := args.Get("poll_frequency")
@@ -147,5 +494,196 @@ func configFromConnectionString(connStr string) (*Config, error) {
 		}
 	}
 
+	if prefetchStr := args.Get("prefetch_pages"); prefetchStr != "" {
+		cfg.PrefetchPages, err = strconv.Atoi(prefetchStr)
+		if err != nil || cfg.PrefetchPages < 0 {
+			return nil, fmt.Errorf("invalid prefetch_pages parameter: %s", prefetchStr)
+		}
+	}
+
+	cfg.ResultMode = ResultMode(args.Get("result_mode"))
+	if err := validateResultMode(cfg.ResultMode); err != nil {
+		return nil, err
+	}
+	cfg.UnloadLocation = args.Get("unload_location")
+
+	if pageSizeStr := args.Get("page_size"); pageSizeStr != "" {
+		cfg.PageSize, err = strconv.Atoi(pageSizeStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page_size parameter: %s", pageSizeStr)
+		}
+		if err := validatePageSize(cfg.PageSize); err != nil {
+			return nil, err
+		}
+	}
+
+	if maxRowsStr := args.Get("max_rows"); maxRowsStr != "" {
+		cfg.MaxRows, err = strconv.ParseInt(maxRowsStr, 10, 64)
+		if err != nil || cfg.MaxRows < 0 {
+			return nil, fmt.Errorf("invalid max_rows parameter: %s", maxRowsStr)
+		}
+	}
+
+	if stopStr := args.Get("stop_at_max_rows"); stopStr != "" {
+		cfg.StopAtMaxRows, err = strconv.ParseBool(stopStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stop_at_max_rows parameter: %s", stopStr)
+		}
+	}
+
+	if dedupStr := args.Get("dedup_queries"); dedupStr != "" {
+		cfg.DedupQueries, err = strconv.ParseBool(dedupStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dedup_queries parameter: %s", dedupStr)
+		}
+	}
+
+	if maxBytesStr := args.Get("max_bytes_scanned"); maxBytesStr != "" {
+		cfg.MaxBytesScanned, err = strconv.ParseInt(maxBytesStr, 10, 64)
+		if err != nil || cfg.MaxBytesScanned < 0 {
+			return nil, fmt.Errorf("invalid max_bytes_scanned parameter: %s", maxBytesStr)
+		}
+	}
+
+	if option := args.Get("encryption"); option != "" {
+		cfg.Encryption = &Encryption{Option: option, KMSKey: args.Get("kms_key")}
+	} else if args.Get("kms_key") != "" {
+		return nil, errors.New("kms_key parameter requires encryption")
+	}
+	cfg.ExpectedBucketOwner = args.Get("expected_bucket_owner")
+	cfg.S3ACLOption = args.Get("s3_acl_option")
+	if err := cfg.validateResultConfiguration(); err != nil {
+		return nil, err
+	}
+
+	if app := args.Get("app"); app != "" {
+		cfg.Attribution = &Attribution{App: app}
+	}
+
+	if maxAgeStr := args.Get("result_reuse_max_age"); maxAgeStr != "" {
+		cfg.ResultReuseMaxAge, err = time.ParseDuration(maxAgeStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid result_reuse_max_age parameter: %s", maxAgeStr)
+		}
+		if err := validateResultReuseMaxAge(cfg.ResultReuseMaxAge); err != nil {
+			return nil, err
+		}
+	}
+
 	return &cfg, nil
 }
+
+// envFallbacks are the DSN parameters that fall back to environment
+// variables, in the order they're reported missing.
+var envFallbacks = []struct {
+	key, env string
+	required bool
+}{
+	{key: "db", env: "ATHENA_DATABASE", required: true},
+	{key: "output_location", env: "ATHENA_OUTPUT_LOCATION", required: true},
+	{key: "workgroup", env: "ATHENA_WORKGROUP"},
+	{key: "catalog", env: "ATHENA_CATALOG"},
+	{key: "poll_frequency", env: "ATHENA_POLL_FREQUENCY"},
+}
+
+// withEnvFallbacks sets the parameters missing from args, or empty, to their
+// environment variables, if those are set.
+func withEnvFallbacks(args url.Values) {
+	for _, f := range envFallbacks {
+		if args.Get(f.key) != "" {
+			continue
+		}
+		if v := os.Getenv(f.env); v != "" {
+			args.Set(f.key, v)
+		}
+	}
+}
+
+// FormatDSN returns a DSN for sql.Open("athena", ...) that configures the
+// driver as c does. Settings that can't be written in a DSN are left out:
+// Session, API, S3, Cache, CacheTTL, CacheMaxRows, Admission, Hooks, the Log*
+// ones and all of Attribution but App.
+func (c *Config) FormatDSN() string {
+	args := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			args.Set(key, value)
+		}
+	}
+
+	set("db", c.Database)
+	set("workgroup", c.WorkGroup)
+	set("catalog", c.Catalog)
+	set("output_location", c.OutputLocation)
+	if c.PollFrequency != 0 {
+		set("poll_frequency", c.PollFrequency.String())
+	}
+	if c.PrefetchPages != 0 {
+		set("prefetch_pages", strconv.Itoa(c.PrefetchPages))
+	}
+	set("result_mode", string(c.ResultMode))
+	set("unload_location", c.UnloadLocation)
+	if c.PageSize != 0 {
+		set("page_size", strconv.Itoa(c.PageSize))
+	}
+	if c.MaxRows != 0 {
+		set("max_rows", strconv.FormatInt(c.MaxRows, 10))
+	}
+	if c.StopAtMaxRows {
+		set("stop_at_max_rows", "true")
+	}
+	if c.ResultReuseMaxAge != 0 {
+		set("result_reuse_max_age", c.ResultReuseMaxAge.String())
+	}
+	if c.DedupQueries {
+		set("dedup_queries", "true")
+	}
+	if c.MaxBytesScanned != 0 {
+		set("max_bytes_scanned", strconv.FormatInt(c.MaxBytesScanned, 10))
+	}
+
+	if c.Encryption != nil {
+		set("encryption", c.Encryption.Option)
+		set("kms_key", c.Encryption.KMSKey)
+	}
+	set("expected_bucket_owner", c.ExpectedBucketOwner)
+	set("s3_acl_option", c.S3ACLOption)
+	if c.Attribution != nil {
+		set("app", c.Attribution.App)
+	}
+
+	set("region", c.Region)
+	set("profile", c.Profile)
+	set("role_arn", c.RoleARN)
+	set("external_id", c.ExternalID)
+	set("role_session_name", c.RoleSessionName)
+	set("endpoint", c.Endpoint)
+	if c.MaxRetries != nil {
+		set("max_retries", strconv.Itoa(*c.MaxRetries))
+	}
+	if c.HTTPTimeout != 0 {
+		set("http_timeout", c.HTTPTimeout.String())
+	}
+
+	return args.Encode()
+}
+
+// maxResultReuseMaxAge is the longest Athena will reuse query results for.
+const maxResultReuseMaxAge = 7 * 24 * time.Hour
+
+func validateResultReuseMaxAge(maxAge time.Duration) error {
+	if maxAge != 0 && (maxAge < time.Minute || maxAge > maxResultReuseMaxAge) {
+		return fmt.Errorf("result reuse max age must be between %s and %s, not %s", time.Minute, maxResultReuseMaxAge, maxAge)
+	}
+	return nil
+}
+
+// maxPageSize is the most rows Athena returns per page of results.
+const maxPageSize = 1000
+
+func validatePageSize(size int) error {
+	if size < 0 || size > maxPageSize {
+		return fmt.Errorf("page size must be between 1 and %d, not %d", maxPageSize, size)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package athena
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/athena"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromConnectionString_ResultConfiguration(t *testing.T) {
+	cfg, err := configFromConnectionString("db=db&output_location=s3://results" +
+		"&encryption=SSE_KMS&kms_key=arn:aws:kms:us-east-1:123456789012:key/abc" +
+		"&expected_bucket_owner=123456789012&s3_acl_option=BUCKET_OWNER_FULL_CONTROL")
+	require.NoError(t, err)
+	assert.Equal(t, &Encryption{Option: EncryptionSSEKMS, KMSKey: "arn:aws:kms:us-east-1:123456789012:key/abc"}, cfg.Encryption)
+	assert.Equal(t, "123456789012", cfg.ExpectedBucketOwner)
+	assert.Equal(t, S3ACLBucketOwnerFullControl, cfg.S3ACLOption)
+
+	for dsn, msg := range map[string]string{
+		"encryption=SSE_KMS":            "encryption option SSE_KMS requires a KMS key",
+		"encryption=SSE_S3&kms_key=key": "encryption option SSE_S3 doesn't take a KMS key",
+		"encryption=AES":                `invalid encryption option "AES"`,
+		"kms_key=key":                   "kms_key parameter requires encryption",
+		"expected_bucket_owner=me":      `expected bucket owner must be a 12 digit AWS account ID, not "me"`,
+		"s3_acl_option=PUBLIC_READ":     `invalid S3 ACL option "PUBLIC_READ"`,
+	} {
+		_, err := configFromConnectionString("db=db&output_location=s3://results&" + dsn)
+		assert.EqualError(t, err, msg, dsn)
+	}
+}
+
+func TestConfigFromConnectionString_Catalog(t *testing.T) {
+	cfg, err := configFromConnectionString("db=db&output_location=s3://results&catalog=dynamodb")
+	require.NoError(t, err)
+	assert.Equal(t, "dynamodb", cfg.Catalog)
+	assert.Contains(t, cfg.FormatDSN(), "catalog=dynamodb")
+
+	parsed, err := configFromConnectionString(cfg.FormatDSN())
+	require.NoError(t, err)
+	assert.Equal(t, "dynamodb", parsed.Catalog)
+
+	cfg, err = configFromConnectionString("db=db&output_location=s3://results")
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Catalog)
+	assert.NotContains(t, cfg.FormatDSN(), "catalog=")
+}
+
+func TestConfig_Validate_ResultConfiguration(t *testing.T) {
+	cfg := Config{
+		Session:        session.Must(session.NewSession()),
+		Database:       "db",
+		OutputLocation: "s3://results",
+		Encryption:     &Encryption{Option: EncryptionCSEKMS, KMSKey: "key"},
+	}
+	assert.NoError(t, cfg.validate())
+
+	cfg.Encryption.KMSKey = ""
+	assert.EqualError(t, cfg.validate(), "encryption option CSE_KMS requires a KMS key")
+}
+
+func TestConfig_FormatDSN(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config")
+	require.NoError(t, os.WriteFile(configFile, []byte("[profile analytics]\nregion = eu-west-1\n"), 0600))
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+
+	retries := 5
+	cfg := Config{
+		Database:            "db",
+		WorkGroup:           "primary",
+		Catalog:             "dynamodb",
+		OutputLocation:      "s3://results/prefix",
+		PollFrequency:       2 * time.Second,
+		ResultReuseMaxAge:   time.Hour,
+		DedupQueries:        true,
+		MaxBytesScanned:     1 << 30,
+		Encryption:          &Encryption{Option: EncryptionSSEKMS, KMSKey: "arn:aws:kms:eu-west-1:123456789012:key/abc"},
+		ExpectedBucketOwner: "123456789012",
+		S3ACLOption:         S3ACLBucketOwnerFullControl,
+		Attribution:         &Attribution{App: "billing"},
+		Profile:             "analytics",
+		RoleARN:             "arn:aws:iam::123456789012:role/athena",
+		ExternalID:          "secret&id",
+		RoleSessionName:     "go-athena",
+		Endpoint:            "http://localhost:4566",
+		MaxRetries:          &retries,
+		HTTPTimeout:         30 * time.Second,
+	}
+
+	parsed, err := configFromConnectionString(cfg.FormatDSN())
+	require.NoError(t, err)
+
+	sess := parsed.Session
+	require.NotNil(t, sess)
+	assert.Equal(t, "eu-west-1", aws.StringValue(sess.Config.Region), "region is read from the profile")
+	assert.Nil(t, sess.Config.Endpoint, "the endpoint is only Athena's")
+	assert.Equal(t, 5, aws.IntValue(sess.Config.MaxRetries))
+	assert.Equal(t, 30*time.Second, sess.Config.HTTPClient.Timeout)
+
+	parsed.Session = nil
+	assert.Equal(t, cfg, *parsed)
+
+	cfg = Config{Database: "db", OutputLocation: "s3://results", Region: "us-east-1"}
+	parsed, err = configFromConnectionString(cfg.FormatDSN())
+	require.NoError(t, err)
+	assert.Equal(t, "us-east-1", aws.StringValue(parsed.Session.Config.Region))
+	assert.Nil(t, parsed.MaxRetries)
+}
+
+func TestNewConn_Endpoint(t *testing.T) {
+	cfg, err := configFromConnectionString("db=db&output_location=s3://results&region=eu-west-1" +
+		"&role_arn=arn:aws:iam::123456789012:role/athena&endpoint=http://localhost:4566")
+	require.NoError(t, err)
+	c := newConn(cfg)
+
+	client := c.athena.(v1API).client.(*athena.Athena)
+	assert.Equal(t, "http://localhost:4566", client.Endpoint)
+
+	// The clients reading unloaded results and assuming the role keep
+	// their own endpoints.
+	assert.Equal(t, "https://s3.eu-west-1.amazonaws.com", c.s3.(v1S3API).client.(*s3.S3).Endpoint)
+	assert.Regexp(t, `^https://sts\.([a-z0-9-]+\.)?amazonaws\.com$`, sts.New(cfg.Session).Endpoint)
+}
+
+func TestConfigFromConnectionString_Session(t *testing.T) {
+	for dsn, msg := range map[string]string{
+		"max_retries=-1":      "invalid max_retries parameter: -1",
+		"max_retries=many":    "invalid max_retries parameter: many",
+		"http_timeout=1":      "invalid http_timeout parameter: 1",
+		"external_id=id":      "external_id and role_session_name require role_arn",
+		"role_session_name=s": "external_id and role_session_name require role_arn",
+	} {
+		_, err := configFromConnectionString("db=db&output_location=s3://results&" + dsn)
+		assert.EqualError(t, err, msg, dsn)
+	}
+}
+
+func TestConfigFromConnectionString_Env(t *testing.T) {
+	t.Setenv("ATHENA_DATABASE", "env_db")
+	t.Setenv("ATHENA_OUTPUT_LOCATION", "s3://env-results")
+	t.Setenv("ATHENA_WORKGROUP", "env_workgroup")
+	t.Setenv("ATHENA_CATALOG", "env_catalog")
+	t.Setenv("ATHENA_POLL_FREQUENCY", "10s")
+
+	cfg, err := configFromConnectionString("")
+	require.NoError(t, err)
+	assert.Equal(t, "env_db", cfg.Database)
+	assert.Equal(t, "s3://env-results", cfg.OutputLocation)
+	assert.Equal(t, "env_workgroup", cfg.WorkGroup)
+	assert.Equal(t, "env_catalog", cfg.Catalog)
+	assert.Equal(t, 10*time.Second, cfg.PollFrequency)
+
+	// The DSN takes precedence, unless its parameters are empty.
+	cfg, err = configFromConnectionString("db=db&workgroup=&poll_frequency=1s")
+	require.NoError(t, err)
+	assert.Equal(t, "db", cfg.Database)
+	assert.Equal(t, "s3://env-results", cfg.OutputLocation)
+	assert.Equal(t, "env_workgroup", cfg.WorkGroup)
+	assert.Equal(t, time.Second, cfg.PollFrequency)
+
+	t.Setenv("ATHENA_DATABASE", "")
+	t.Setenv("ATHENA_OUTPUT_LOCATION", "")
+	_, err = configFromConnectionString("")
+	assert.EqualError(t, err, "missing required settings: db (or ATHENA_DATABASE), output_location (or ATHENA_OUTPUT_LOCATION)")
+	_, err = configFromConnectionString("output_location=s3://results")
+	assert.EqualError(t, err, "missing required settings: db (or ATHENA_DATABASE)")
+}
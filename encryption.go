@@ -0,0 +1,58 @@
+package athena
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Options for encrypting query results.
+const (
+	EncryptionSSES3  = "SSE_S3"
+	EncryptionSSEKMS = "SSE_KMS"
+	EncryptionCSEKMS = "CSE_KMS"
+)
+
+// S3ACLBucketOwnerFullControl is the only S3 ACL option Config.S3ACLOption
+// supports.
+const S3ACLBucketOwnerFullControl = "BUCKET_OWNER_FULL_CONTROL"
+
+// Encryption configures how Athena encrypts query results in S3.
+type Encryption struct {
+	// Option is one of EncryptionSSES3, EncryptionSSEKMS or EncryptionCSEKMS.
+	Option string
+	// KMSKey is the ARN or ID of the KMS key. It's required for SSE_KMS and
+	// CSE_KMS, and must be empty for SSE_S3.
+	KMSKey string
+}
+
+func (e *Encryption) validate() error {
+	switch e.Option {
+	case EncryptionSSES3:
+		if e.KMSKey != "" {
+			return fmt.Errorf("encryption option %s doesn't take a KMS key", e.Option)
+		}
+	case EncryptionSSEKMS, EncryptionCSEKMS:
+		if e.KMSKey == "" {
+			return fmt.Errorf("encryption option %s requires a KMS key", e.Option)
+		}
+	default:
+		return fmt.Errorf("invalid encryption option %q", e.Option)
+	}
+	return nil
+}
+
+var accountIDPattern = regexp.MustCompile(`^[0-9]{12}$`)
+
+func validateExpectedBucketOwner(owner string) error {
+	if owner != "" && !accountIDPattern.MatchString(owner) {
+		return fmt.Errorf("expected bucket owner must be a 12 digit AWS account ID, not %q", owner)
+	}
+	return nil
+}
+
+func validateS3ACLOption(option string) error {
+	if option != "" && option != S3ACLBucketOwnerFullControl {
+		return fmt.Errorf("invalid S3 ACL option %q", option)
+	}
+	return nil
+}
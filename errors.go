@@ -0,0 +1,26 @@
+package athena
+
+import "fmt"
+
+// QueryError is returned for queries that Athena reports as failed.
+type QueryError struct {
+	QueryID string
+	// Status is the query's final status. Its StateChangeReason says why it
+	// failed, and ErrorCategory, ErrorType and Retryable detail it.
+	Status QueryStatus
+}
+
+func (e *QueryError) Error() string {
+	return e.Status.StateChangeReason
+}
+
+// ErrRowLimitExceeded is returned by rows that have more than Config.MaxRows,
+// once that many have been read.
+type ErrRowLimitExceeded struct {
+	QueryID string
+	MaxRows int64
+}
+
+func (e *ErrRowLimitExceeded) Error() string {
+	return fmt.Sprintf("query %s returned more than the limit of %d rows", e.QueryID, e.MaxRows)
+}
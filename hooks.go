@@ -0,0 +1,254 @@
+package athena
+
+import (
+	"context"
+	"time"
+)
+
+// Hooks are callbacks that follow a query through its lifecycle, e.g. to show
+// its progress or to record metrics. Any of them may be nil. They're called
+// synchronously from the goroutine running the query, so they should be quick.
+//
+// Hooks are set on Config.Hooks and can be replaced for a single query with
+// athena.WithHooks(). Use ComposeHooks() to install several sets at once.
+type Hooks struct {
+	// OnSubmit is called before a query is submitted to Athena, if the driver
+	// waits on it with the same context, as in db.Query(). The context it
+	// returns, unless nil, is used instead of ctx from then on: it's passed to
+	// the other hooks, to the calls made to Athena and to the query's
+	// results, e.g. to carry a tracing span. Such queries always get an
+	// OnComplete call, even if they couldn't be started, and if they
+	// succeeded, an OnClose call. Queries started with Client.StartQuery() or
+	// RunBatch() don't call it.
+	OnSubmit func(ctx context.Context, query string) context.Context
+
+	// OnStart is called once Athena has accepted a query.
+	OnStart func(ctx context.Context, info QueryInfo)
+
+	// OnPoll is called with every status fetched while waiting on a query.
+	OnPoll func(ctx context.Context, info QueryInfo, status QueryStatus)
+
+	// OnPollError is called whenever a query's status couldn't be fetched,
+	// including when BatchGetQueryExecution leaves it unprocessed. Unless
+	// info.StatementType is set, the query hadn't been polled successfully
+	// yet, and only info.QueryID is known.
+	OnPollError func(ctx context.Context, info QueryInfo, err error)
+
+	// OnStateChange is called when polling finds a query in a new state,
+	// including the first time it's polled, in which case from is empty.
+	OnStateChange func(ctx context.Context, info QueryInfo, from, to string)
+
+	// OnComplete is called once the driver stops waiting on a query, with the
+	// error it failed with, if any. Unless err is nil, the query may not have
+	// finished, e.g. if ctx was done first or Athena couldn't be polled, or
+	// even started, in which case info.QueryID is empty.
+	OnComplete func(ctx context.Context, info QueryInfo, status QueryStatus, err error)
+
+	// OnPage is called after each page of results is fetched. With
+	// ResultModeUnload, each Parquet file is a page, and for the CSV files
+	// Client.QueryArrow() reads, each record batch.
+	OnPage func(ctx context.Context, info QueryInfo, page PageInfo)
+
+	// OnClose is called when a query's results are closed, with the number of
+	// rows that were read from them. It's called with 0 rows if they couldn't
+	// be opened.
+	OnClose func(ctx context.Context, info QueryInfo, rows int)
+}
+
+// QueryInfo identifies the query a hook is called for.
+type QueryInfo struct {
+	QueryID   string
+	Query     string
+	Database  string
+	WorkGroup string
+	// StatementType is one of the StatementType* constants.
+	// It's unknown, and so empty, in OnStart.
+	StatementType string
+
+	// SubmittedAt is when the query was submitted to Athena.
+	SubmittedAt time.Time
+
+	// Joined is set in OnComplete for callers that waited on an identical
+	// query another caller started, with Config.DedupQueries. The query's
+	// status is also reported to the caller that started it, so it shouldn't
+	// be counted twice.
+	Joined bool
+}
+
+// PageInfo describes a page of results fetched from Athena.
+type PageInfo struct {
+	// Number counts pages from 1.
+	Number int
+	// Rows is the number of rows on the page, not counting the header row.
+	Rows int
+
+	StartedAt time.Time
+	Duration  time.Duration
+	Err       error
+}
+
+// ComposeHooks returns Hooks that call each of hooks in turn.
+func ComposeHooks(hooks ...Hooks) Hooks {
+	return Hooks{
+		OnSubmit: func(ctx context.Context, query string) context.Context {
+			for _, h := range hooks {
+				if h.OnSubmit != nil {
+					if submitCtx := h.OnSubmit(ctx, query); submitCtx != nil {
+						ctx = submitCtx
+					}
+				}
+			}
+			return ctx
+		},
+		OnStart: func(ctx context.Context, info QueryInfo) {
+			for _, h := range hooks {
+				if h.OnStart != nil {
+					h.OnStart(ctx, info)
+				}
+			}
+		},
+		OnPoll: func(ctx context.Context, info QueryInfo, status QueryStatus) {
+			for _, h := range hooks {
+				if h.OnPoll != nil {
+					h.OnPoll(ctx, info, status)
+				}
+			}
+		},
+		OnPollError: func(ctx context.Context, info QueryInfo, err error) {
+			for _, h := range hooks {
+				if h.OnPollError != nil {
+					h.OnPollError(ctx, info, err)
+				}
+			}
+		},
+		OnStateChange: func(ctx context.Context, info QueryInfo, from, to string) {
+			for _, h := range hooks {
+				if h.OnStateChange != nil {
+					h.OnStateChange(ctx, info, from, to)
+				}
+			}
+		},
+		OnComplete: func(ctx context.Context, info QueryInfo, status QueryStatus, err error) {
+			for _, h := range hooks {
+				if h.OnComplete != nil {
+					h.OnComplete(ctx, info, status, err)
+				}
+			}
+		},
+		OnPage: func(ctx context.Context, info QueryInfo, page PageInfo) {
+			for _, h := range hooks {
+				if h.OnPage != nil {
+					h.OnPage(ctx, info, page)
+				}
+			}
+		},
+		OnClose: func(ctx context.Context, info QueryInfo, rows int) {
+			for _, h := range hooks {
+				if h.OnClose != nil {
+					h.OnClose(ctx, info, rows)
+				}
+			}
+		},
+	}
+}
+
+func (c *conn) hooksFor(ctx context.Context) Hooks {
+	if hooks, ok := hooksFromContext(ctx); ok {
+		return hooks
+	}
+	return c.hooks
+}
+
+func newQueryInfo(execution *QueryExecution) QueryInfo {
+	return QueryInfo{
+		QueryID:   execution.QueryID,
+		Query:     execution.Query,
+		Database:  execution.Database,
+		WorkGroup: execution.WorkGroup,
+
+		StatementType: execution.StatementType,
+		SubmittedAt:   execution.Status.SubmissionDateTime,
+	}
+}
+
+// submit fires OnSubmit, returning the context to run the query with.
+func (h Hooks) submit(ctx context.Context, query string) context.Context {
+	if h.OnSubmit != nil {
+		if submitCtx := h.OnSubmit(ctx, query); submitCtx != nil {
+			return submitCtx
+		}
+	}
+	return ctx
+}
+
+func (h Hooks) start(ctx context.Context, info QueryInfo) {
+	if h.OnStart != nil {
+		h.OnStart(ctx, info)
+	}
+}
+
+// poll fires OnPoll for a polled execution, and OnStateChange if its state
+// differs from the previous one. It returns the execution's state.
+func (h Hooks) poll(ctx context.Context, execution *QueryExecution, prevState string) string {
+	state := execution.Status.State
+	if h.OnPoll == nil && h.OnStateChange == nil {
+		return state
+	}
+
+	info := newQueryInfo(execution)
+	if h.OnPoll != nil {
+		h.OnPoll(ctx, info, newQueryStatus(execution))
+	}
+	if h.OnStateChange != nil && state != prevState {
+		h.OnStateChange(ctx, info, prevState, state)
+	}
+	return state
+}
+
+// pollFailed fires OnPollError. execution is the last state seen of the
+// query, if it was ever polled successfully.
+func (h Hooks) pollFailed(ctx context.Context, queryID string, execution *QueryExecution, err error) {
+	if h.OnPollError == nil {
+		return
+	}
+
+	info := QueryInfo{QueryID: queryID}
+	if execution != nil {
+		info = newQueryInfo(execution)
+	}
+	h.OnPollError(ctx, info, err)
+}
+
+// complete fires OnComplete. execution is the last state seen of the query,
+// if it was ever polled successfully.
+func (h Hooks) complete(ctx context.Context, queryID string, execution *QueryExecution, err error) {
+	h.completeQuery(ctx, QueryInfo{QueryID: queryID}, execution, err)
+}
+
+// completeQuery fires OnComplete for a query described by info, unless it
+// was polled successfully, in which case execution is its last state seen.
+func (h Hooks) completeQuery(ctx context.Context, info QueryInfo, execution *QueryExecution, err error) {
+	if h.OnComplete == nil {
+		return
+	}
+
+	status := QueryStatus{Stats: QueryStats{QueryID: info.QueryID}}
+	if execution != nil {
+		joined := info.Joined
+		info, status = newQueryInfo(execution), newQueryStatus(execution)
+		info.Joined = joined
+	}
+	h.OnComplete(ctx, info, status, err)
+}
+
+func (h Hooks) page(ctx context.Context, info QueryInfo, page PageInfo) {
+	if h.OnPage != nil {
+		h.OnPage(ctx, info, page)
+	}
+}
+
+func (h Hooks) close(ctx context.Context, info QueryInfo, rows int) {
+	if h.OnClose != nil {
+		h.OnClose(ctx, info, rows)
+	}
+}
@@ -0,0 +1,216 @@
+package athena
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recordingHooks(events *[]string) Hooks {
+	return Hooks{
+		OnStart: func(_ context.Context, info QueryInfo) {
+			*events = append(*events, "start "+info.QueryID)
+		},
+		OnPoll: func(_ context.Context, info QueryInfo, status QueryStatus) {
+			*events = append(*events, "poll "+status.State)
+		},
+		OnStateChange: func(_ context.Context, info QueryInfo, from, to string) {
+			*events = append(*events, fmt.Sprintf("state %q -> %q", from, to))
+		},
+		OnComplete: func(_ context.Context, info QueryInfo, status QueryStatus, err error) {
+			*events = append(*events, fmt.Sprintf("complete %s %v", status.State, err))
+		},
+		OnPage: func(_ context.Context, info QueryInfo, page PageInfo) {
+			*events = append(*events, fmt.Sprintf("page %d: %d rows", page.Number, page.Rows))
+		},
+	}
+}
+
+func TestConn_Hooks(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"select": {
+			QueryStateQueued,
+			QueryStateRunning,
+			QueryStateRunning,
+			QueryStateSucceeded,
+		},
+	}}
+	var events []string
+	c := &conn{athena: mock, pollFrequency: time.Millisecond, hooks: recordingHooks(&events)}
+
+	r, err := c.QueryContext(context.Background(), "select", nil)
+	require.NoError(t, err)
+	for {
+		var firstName, lastName string
+		if err := r.Next(castToValue(&firstName, &lastName)); err == io.EOF {
+			break
+		}
+	}
+
+	assert.Equal(t, []string{
+		"start select",
+		"poll QUEUED",
+		`state "" -> "QUEUED"`,
+		"poll RUNNING",
+		`state "QUEUED" -> "RUNNING"`,
+		"poll RUNNING",
+		"poll SUCCEEDED",
+		`state "RUNNING" -> "SUCCEEDED"`,
+		"complete SUCCEEDED <nil>",
+		"page 1: 4 rows",
+		"page 2: 5 rows",
+	}, events)
+}
+
+func TestWithHooks(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"failed": {QueryStateFailed},
+	}}
+	var configured, overridden []string
+	c := &conn{athena: mock, pollFrequency: time.Millisecond, hooks: recordingHooks(&configured)}
+
+	ctx := WithHooks(context.Background(), ComposeHooks(Hooks{}, recordingHooks(&overridden)))
+	_, err := c.QueryContext(ctx, "failed", nil)
+	assert.Error(t, err)
+
+	assert.Empty(t, configured)
+	assert.Equal(t, []string{
+		"start failed",
+		"poll FAILED",
+		`state "" -> "FAILED"`,
+		"complete FAILED reason",
+	}, overridden)
+}
+
+func TestHooks_OnSubmit(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"select": {QueryStateSucceeded},
+	}}
+	type key string
+	var completed []interface{}
+	hooks := ComposeHooks(
+		Hooks{OnSubmit: func(ctx context.Context, query string) context.Context {
+			return context.WithValue(ctx, key("first"), query)
+		}},
+		Hooks{OnSubmit: func(ctx context.Context, query string) context.Context {
+			return nil
+		}},
+		Hooks{
+			OnSubmit: func(ctx context.Context, query string) context.Context {
+				return context.WithValue(ctx, key("second"), ctx.Value(key("first")))
+			},
+			OnComplete: func(ctx context.Context, _ QueryInfo, _ QueryStatus, _ error) {
+				completed = append(completed, ctx.Value(key("first")), ctx.Value(key("second")))
+			},
+		},
+	)
+	client := &Client{conn: &conn{athena: mock, pollFrequency: time.Millisecond, hooks: hooks}}
+
+	r, err := client.conn.QueryContext(context.Background(), "select", nil)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, []interface{}{"select", "select"}, completed)
+
+	// Queries that aren't waited on with the context they're started with
+	// don't call it.
+	completed = nil
+	handle, err := client.StartQuery(context.Background(), "select")
+	require.NoError(t, err)
+	require.NoError(t, handle.Wait(context.Background()))
+	assert.Equal(t, []interface{}{nil, nil}, completed)
+}
+
+// failingAthenaClient fails the calls it has an error for.
+type failingAthenaClient struct {
+	AthenaAPI
+	startErr, pollErr, resultsErr error
+}
+
+func (m failingAthenaClient) StartQueryExecution(ctx context.Context, input *StartQueryExecutionInput) (*StartQueryExecutionOutput, error) {
+	if m.startErr != nil {
+		return nil, m.startErr
+	}
+	return m.AthenaAPI.StartQueryExecution(ctx, input)
+}
+
+func (m failingAthenaClient) GetQueryExecution(ctx context.Context, input *GetQueryExecutionInput) (*QueryExecution, error) {
+	if m.pollErr != nil {
+		return nil, m.pollErr
+	}
+	return m.AthenaAPI.GetQueryExecution(ctx, input)
+}
+
+func (m failingAthenaClient) GetQueryResults(ctx context.Context, input *GetQueryResultsInput) (*GetQueryResultsOutput, error) {
+	if m.resultsErr != nil {
+		return nil, m.resultsErr
+	}
+	return m.AthenaAPI.GetQueryResults(ctx, input)
+}
+
+func TestHooks_Lifecycle(t *testing.T) {
+	type key struct{}
+	var events []string
+	hooks := Hooks{
+		OnSubmit: func(ctx context.Context, query string) context.Context {
+			events = append(events, "submit "+query)
+			return context.WithValue(ctx, key{}, query)
+		},
+		OnPollError: func(ctx context.Context, info QueryInfo, err error) {
+			events = append(events, fmt.Sprintf("poll error %s: %v", info.QueryID, err))
+		},
+		OnComplete: func(ctx context.Context, info QueryInfo, status QueryStatus, err error) {
+			events = append(events, fmt.Sprintf("complete %v %q %s %s: %v", ctx.Value(key{}), info.QueryID, info.Database, status.State, err))
+		},
+		OnClose: func(ctx context.Context, info QueryInfo, rows int) {
+			events = append(events, fmt.Sprintf("close %v %s: %d rows", ctx.Value(key{}), info.QueryID, rows))
+		},
+	}
+	query := func(api AthenaAPI) error {
+		events = nil
+		c := &conn{athena: api, db: "db", pollFrequency: time.Millisecond, hooks: hooks}
+		r, err := c.QueryContext(context.Background(), "select", nil)
+		if err != nil {
+			return err
+		}
+		return r.Close()
+	}
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"select": {QueryStateSucceeded},
+	}}
+
+	// Results are read with the context OnSubmit returned.
+	require.NoError(t, query(mock))
+	assert.Equal(t, []string{
+		"submit select",
+		// The mock's executions have no database.
+		`complete select "select"  SUCCEEDED: <nil>`,
+		"close select select: 0 rows",
+	}, events)
+
+	// Queries that can't be started, or polled, still complete.
+	assert.Equal(t, dummyError, query(failingAthenaClient{AthenaAPI: mock, startErr: dummyError}))
+	assert.Equal(t, []string{
+		"submit select",
+		`complete select "" db : dummy error`,
+	}, events)
+
+	assert.Equal(t, dummyError, query(failingAthenaClient{AthenaAPI: mock, pollErr: dummyError}))
+	assert.Equal(t, []string{
+		"submit select",
+		"poll error select: dummy error",
+		`complete select "select" db : dummy error`,
+	}, events)
+
+	// Results that can't be opened are closed right away.
+	assert.Equal(t, dummyError, query(failingAthenaClient{AthenaAPI: mock, resultsErr: dummyError}))
+	assert.Equal(t, []string{
+		"submit select",
+		`complete select "select"  SUCCEEDED: <nil>`,
+		"close select select: 0 rows",
+	}, events)
+}
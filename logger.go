@@ -0,0 +1,103 @@
+package athena
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/segmentio/go-athena/presto"
+)
+
+// Logger is what the driver logs to. *slog.Logger implements it.
+//
+// The driver logs queries being started (at debug level), finishing (info),
+// being cancelled (info) or failing (error), and AWS requests being retried
+// (warn), unless Config.API is set. args are alternating keys and values, as
+// with slog.
+type Logger interface {
+	Log(ctx context.Context, level slog.Level, msg string, args ...any)
+}
+
+// queryLogger logs on behalf of a conn. A nil *queryLogger logs nothing.
+type queryLogger struct {
+	logger        Logger
+	level         slog.Level
+	logParameters bool
+}
+
+func newQueryLogger(cfg *Config) *queryLogger {
+	if cfg.Logger == nil {
+		return nil
+	}
+	return &queryLogger{
+		logger:        cfg.Logger,
+		level:         cfg.LogLevel,
+		logParameters: cfg.LogParameters,
+	}
+}
+
+func (l *queryLogger) log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if l == nil || level < l.level {
+		return
+	}
+	l.logger.Log(ctx, level, msg, args...)
+}
+
+// query returns a query as it should be logged. Its parameters were formatted
+// into it, so every literal is redacted unless parameters should be logged.
+func (l *queryLogger) query(query string) string {
+	if l.logParameters {
+		return query
+	}
+	return presto.Redact(query)
+}
+
+func (l *queryLogger) started(ctx context.Context, info QueryInfo) {
+	// Don't bother redacting the query if it won't be logged.
+	if l == nil || slog.LevelDebug < l.level {
+		return
+	}
+	l.log(ctx, slog.LevelDebug, "athena: query started",
+		"query_id", info.QueryID,
+		"database", info.Database,
+		"workgroup", info.WorkGroup,
+		"query", l.query(info.Query),
+	)
+}
+
+func (l *queryLogger) startFailed(ctx context.Context, query string, err error) {
+	if l == nil {
+		return
+	}
+	l.log(ctx, slog.LevelError, "athena: failed to start query",
+		"query", l.query(query),
+		"error", err,
+	)
+}
+
+// finished logs the outcome of waiting on a query. execution is the last
+// state seen of the query, if it was ever polled successfully.
+func (l *queryLogger) finished(ctx context.Context, queryID string, execution *QueryExecution, err error) {
+	if l == nil {
+		return
+	}
+
+	args := []any{"query_id", queryID}
+	if execution != nil {
+		stats := newQueryStats(execution)
+		args = append(args,
+			"state", execution.Status.State,
+			"data_scanned_bytes", stats.DataScannedInBytes,
+			"duration", stats.TotalExecutionTime,
+		)
+	}
+
+	switch {
+	case err == nil:
+		l.log(ctx, slog.LevelInfo, "athena: query finished", args...)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		l.log(ctx, slog.LevelInfo, "athena: query cancelled", append(args, "error", err)...)
+	default:
+		l.log(ctx, slog.LevelError, "athena: query failed", append(args, "error", err)...)
+	}
+}
@@ -0,0 +1,99 @@
+package athena
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger []string
+
+func (l *recordingLogger) Log(_ context.Context, level slog.Level, msg string, args ...any) {
+	*l = append(*l, strings.TrimSpace(fmt.Sprintln(append([]any{level, msg}, args...)...)))
+}
+
+func TestConn_Logger(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"select 'secret'": {QueryStateRunning, QueryStateSucceeded},
+		"failed":          {QueryStateFailed},
+	}}
+	var logs recordingLogger
+	c := &conn{
+		athena:        mock,
+		pollFrequency: time.Millisecond,
+		logger:        newQueryLogger(&Config{Logger: &logs, LogLevel: slog.LevelDebug}),
+	}
+
+	client := &Client{conn: c}
+	query, err := client.StartQuery(context.Background(), "select $1", "secret")
+	require.NoError(t, err)
+	require.NoError(t, query.Wait(context.Background()))
+	require.Len(t, logs, 2)
+	assert.Contains(t, logs[0], "DEBUG athena: query started")
+	assert.True(t, strings.HasSuffix(logs[0], "query select ?"), "parameters are redacted")
+	assert.Contains(t, logs[1], "INFO athena: query finished")
+	assert.Contains(t, logs[1], "state SUCCEEDED")
+
+	logs = nil
+	_, err = c.QueryContext(context.Background(), "failed", nil)
+	require.Error(t, err)
+	require.Len(t, logs, 2)
+	assert.Contains(t, logs[1], "ERROR athena: query failed")
+	assert.Contains(t, logs[1], "error reason")
+
+	c.logger = newQueryLogger(&Config{Logger: &logs, LogLevel: slog.LevelDebug, LogParameters: true})
+	mock.states["select 'secret'"] = []string{QueryStateSucceeded}
+	logs = nil
+	query, err = client.StartQuery(context.Background(), "select $1", "secret")
+	require.NoError(t, err)
+	require.NoError(t, query.Wait(context.Background()))
+	require.Len(t, logs, 2)
+	assert.True(t, strings.HasSuffix(logs[0], "query select 'secret'"))
+
+	c.logger = newQueryLogger(&Config{Logger: &logs})
+	mock.states["select 'secret'"] = []string{QueryStateSucceeded}
+	logs = nil
+	query, err = client.StartQuery(context.Background(), "select $1", "secret")
+	require.NoError(t, err)
+	require.NoError(t, query.Wait(context.Background()))
+	assert.Len(t, logs, 1, "queries being started are only logged at debug level")
+}
+
+func TestQueryLogger_Retries(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"QueryExecutionId": "id"}`)
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		SleepDelay:  func(time.Duration) {},
+	}))
+
+	var logs recordingLogger
+	c := newConn(&Config{Session: sess, Database: "db", Logger: &logs})
+	_, err := c.startQuery(context.Background(), "select 1")
+	require.NoError(t, err)
+
+	require.Len(t, logs, 1)
+	assert.Contains(t, logs[0], "WARN athena: retrying request operation StartQueryExecution attempt 1")
+}
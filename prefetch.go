@@ -0,0 +1,75 @@
+package athena
+
+import (
+	"context"
+)
+
+// prefetcher fetches pages of results in the background, so that the next
+// one is usually there by the time the current one has been read.
+type prefetcher struct {
+	pages  chan fetchedPage
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// err is the error fetching stopped on, if any. It's set before pages
+	// is closed.
+	err error
+}
+
+// startPrefetch starts fetching the pages after token with get, keeping up to
+// depth of them ahead of the one being read. It stops once the pages hold
+// maxRows rows, if it's set.
+func startPrefetch(ctx context.Context, depth int, maxRows int64, get func(context.Context, string) fetchedPage, token string) *prefetcher {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &prefetcher{
+		// The page waiting to be sent counts towards depth.
+		pages:  make(chan fetchedPage, depth-1),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	limited := maxRows > 0
+	go func() {
+		defer close(p.done)
+		defer close(p.pages)
+		for token != "" {
+			page := get(ctx, token)
+			// The page belongs to the reader once it's sent.
+			if page.info.Err == nil {
+				token = page.out.NextToken
+				maxRows -= int64(len(page.out.Rows))
+			}
+
+			select {
+			case p.pages <- page:
+			case <-ctx.Done():
+				p.err = ctx.Err()
+				return
+			}
+			if page.info.Err != nil {
+				p.err = page.info.Err
+				return
+			}
+			if limited && maxRows <= 0 {
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// next returns the next page, waiting for it to be fetched if needed.
+func (p *prefetcher) next() fetchedPage {
+	page, ok := <-p.pages
+	if !ok {
+		// Fetching stopped on an error, which may have been returned already.
+		return fetchedPage{info: PageInfo{Err: p.err}}
+	}
+	return page
+}
+
+// stop cancels fetching pages and waits for it to stop.
+func (p *prefetcher) stop() {
+	p.cancel()
+	<-p.done
+}
@@ -0,0 +1,106 @@
+package presto
+
+import (
+	"strings"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+	"github.com/segmentio/go-athena/presto/internal"
+)
+
+// Normalize strips comments, collapses whitespace between tokens and
+// upper-cases keywords and unquoted identifiers, so that queries which only
+// differ in formatting normalize to the same string. Literals and quoted
+// identifiers are left untouched.
+func Normalize(sql string) string {
+	is := antlr.NewInputStream(sql)
+	is2 := newUpcaseCharStream(is)
+	lexer := internal.NewSqlBaseLexer(is2)
+
+	var tokens []string
+	for {
+		t := lexer.NextToken()
+		if t.GetTokenType() == antlr.TokenEOF {
+			break
+		}
+
+		// Whitespace and comments are on the hidden channel.
+		if t.GetChannel() == antlr.TokenHiddenChannel {
+			continue
+		}
+
+		switch t.GetTokenType() {
+		case internal.SqlBaseLexerSTRING,
+			internal.SqlBaseLexerUNICODE_STRING,
+			internal.SqlBaseLexerBINARY_LITERAL,
+			internal.SqlBaseLexerQUOTED_IDENTIFIER,
+			internal.SqlBaseLexerBACKQUOTED_IDENTIFIER:
+			tokens = append(tokens, t.GetText())
+		default:
+			tokens = append(tokens, strings.ToUpper(t.GetText()))
+		}
+	}
+
+	return strings.Join(tokens, " ")
+}
+
+// Redact replaces every literal in sql with '?' and strips comments, so that
+// the query can be logged without leaking the values in it.
+func Redact(sql string) string {
+	is := antlr.NewInputStream(sql)
+	is2 := newUpcaseCharStream(is)
+	lexer := internal.NewSqlBaseLexer(is2)
+
+	redacted := strings.Builder{}
+	for {
+		t := lexer.NextToken()
+		if t.GetTokenType() == antlr.TokenEOF {
+			break
+		}
+
+		switch t.GetTokenType() {
+		case internal.SqlBaseLexerSTRING,
+			internal.SqlBaseLexerUNICODE_STRING,
+			internal.SqlBaseLexerBINARY_LITERAL,
+			internal.SqlBaseLexerINTEGER_VALUE,
+			internal.SqlBaseLexerDECIMAL_VALUE,
+			internal.SqlBaseLexerDOUBLE_VALUE:
+			redacted.WriteString("?")
+		case internal.SqlBaseLexerSIMPLE_COMMENT,
+			internal.SqlBaseLexerBRACKETED_COMMENT:
+			redacted.WriteString(" ")
+		default:
+			redacted.WriteString(t.GetText())
+		}
+	}
+
+	return strings.TrimSpace(redacted.String())
+}
+
+// IsQuery reports whether sql is a query that returns rows, i.e. a SELECT,
+// WITH, VALUES or TABLE statement, possibly in parentheses, as opposed to
+// DDL, INSERT, EXPLAIN, SHOW and such.
+func IsQuery(sql string) bool {
+	is := antlr.NewInputStream(sql)
+	is2 := newUpcaseCharStream(is)
+	lexer := internal.NewSqlBaseLexer(is2)
+
+	for {
+		t := lexer.NextToken()
+		switch {
+		case t.GetTokenType() == antlr.TokenEOF:
+			return false
+		case t.GetChannel() == antlr.TokenHiddenChannel, t.GetText() == "(":
+			continue
+		}
+
+		switch t.GetTokenType() {
+		case internal.SqlBaseLexerSELECT,
+			internal.SqlBaseLexerWITH,
+			internal.SqlBaseLexerVALUES,
+			internal.SqlBaseLexerTABLE:
+			return true
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package presto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{
+			sql:      "SELECT 1",
+			expected: "SELECT 1",
+		},
+		{
+			sql:      "  select\n\ta,b   from  t -- trailing comment\n",
+			expected: "SELECT A , B FROM T",
+		},
+		{
+			sql:      "/* leading\ncomment */ SELECT * FROM \"t\" WHERE s = 'a  --  b'",
+			expected: "SELECT * FROM \"t\" WHERE S = 'a  --  b'",
+		},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, Normalize(test.sql), test.sql)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{
+			sql:      "SELECT * FROM t WHERE password = 'hunter2' AND id IN (1, 2.5, 1E3)",
+			expected: "SELECT * FROM t WHERE password = ? AND id IN (?, ?, ?)",
+		},
+		{
+			sql:      "/* token=secret */ SELECT \"col\" FROM t -- 'secret'\nLIMIT 10",
+			expected: "SELECT \"col\" FROM t  LIMIT ?",
+		},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, Redact(test.sql), test.sql)
+	}
+}
+
+func TestIsQuery(t *testing.T) {
+	for sql, expected := range map[string]bool{
+		"SELECT 1":                    true,
+		"/* app=x */ select * from t": true,
+		"-- comment\n  WITH a AS (SELECT 1) TABLE a": true,
+		"(SELECT 1) UNION (SELECT 2)":                true,
+		"VALUES 1, 2":                                true,
+		"SHOW TABLES":                                false,
+		"INSERT INTO t SELECT 1":                     false,
+		"CREATE TABLE t AS SELECT 1":                 false,
+		"EXPLAIN SELECT 1":                           false,
+		"":                                           false,
+	} {
+		assert.Equal(t, expected, IsQuery(sql), sql)
+	}
+}
@@ -1,15 +1,12 @@
 package presto
 
 import (
-	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
 
-	"github.com/davecgh/go-spew/spew"
-
 	"github.com/antlr/antlr4/runtime/Go/antlr"
 	"github.com/pkg/errors"
 	"github.com/segmentio/go-athena/presto/internal"
@@ -57,8 +54,6 @@ func ValidateAndFormatSql(sql string, params ...interface{}) (string, error) {
 
 	}
 
-	fmt.Println(newSql.String())
-
 	return newSql.String(), nil
 }
 
@@ -75,8 +70,7 @@ func formatParameter(p interface{}) (string, error) {
 	case reflect.Bool:
 		return strconv.FormatBool(reflect.ValueOf(p).Bool()), nil
 	default:
-		spew.Dump(p)
-		return "", errors.Errorf("Unsupported data type: %s", reflect.TypeOf(p).Name())
+		return "", errors.Errorf("Unsupported data type: %T", p)
 	}
 }
 
@@ -0,0 +1,403 @@
+package athena
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/segmentio/go-athena/presto"
+)
+
+// Client runs Athena queries without going through database/sql.
+// Unlike db.Query(), it doesn't tie a query to the calling goroutine:
+// you can start a query, persist its execution ID, and wait on or read
+// its results later, even from another process.
+type Client struct {
+	conn *conn
+}
+
+// NewClient returns a Client for the given configuration.
+// It's validated the same way as in athena.Open().
+func NewClient(cfg Config) (*Client, error) {
+	if err := cfg.prepare(); err != nil {
+		return nil, err
+	}
+
+	return &Client{conn: newConn(&cfg)}, nil
+}
+
+// StartQuery starts a query and returns without waiting for it to finish.
+// Arguments are bound the same way as in db.Query().
+func (c *Client) StartQuery(ctx context.Context, query string, args ...interface{}) (*QueryHandle, error) {
+	if len(args) > 0 {
+		var err error
+		query, err = presto.ValidateAndFormatSql(query, args...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	queryID, err := c.conn.startAdmittedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Attach(queryID), nil
+}
+
+// Attach returns a handle to a query that has already been started,
+// e.g. by StartQuery() in another process.
+func (c *Client) Attach(queryID string) *QueryHandle {
+	return &QueryHandle{conn: c.conn, ID: queryID}
+}
+
+// OpenResults opens the results of a query that has already succeeded.
+func (c *Client) OpenResults(ctx context.Context, queryID string) (*Results, error) {
+	execution, err := c.conn.queryExecution(ctx, queryID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.openResults(ctx, execution)
+}
+
+func (c *Client) openResults(ctx context.Context, execution *QueryExecution) (*Results, error) {
+	if state := execution.Status.State; state != QueryStateSucceeded {
+		return nil, fmt.Errorf("query %s is %s, not %s", execution.QueryID, state, QueryStateSucceeded)
+	}
+
+	r, err := c.conn.openRows(ctx, execution, hasHeaderRow(execution))
+	if err != nil {
+		return nil, err
+	}
+
+	return newResults(r), nil
+}
+
+// hasHeaderRow reports whether the first row of a query's results repeats
+// the column names. Athena only adds it for DML (e.g. SELECT) statements.
+func hasHeaderRow(execution *QueryExecution) bool {
+	return execution.StatementType == StatementTypeDML
+}
+
+// QueryHandle refers to a single Athena query execution.
+// It's safe to persist ID and Attach() to it later.
+type QueryHandle struct {
+	conn *conn
+
+	ID string
+}
+
+// QueryStatus is a snapshot of a query's progress.
+type QueryStatus struct {
+	// State is one of the QueryState* constants.
+	State             string
+	StateChangeReason string
+
+	SubmittedAt time.Time
+	CompletedAt time.Time
+
+	// Stats is filled in as the query runs, and is final once it's Done().
+	Stats QueryStats
+
+	// ErrorCategory is one of the ErrorCategory* constants if the query failed
+	// and Athena reported why, and empty otherwise. ErrorType details it, see
+	// https://docs.aws.amazon.com/athena/latest/ug/error-reference.html.
+	ErrorCategory string
+	ErrorType     int64
+	Retryable     bool
+}
+
+// Categories of query failures reported by Athena.
+const (
+	ErrorCategorySystem = "system"
+	ErrorCategoryUser   = "user"
+	ErrorCategoryOther  = "other"
+)
+
+var errorCategories = map[int64]string{
+	1: ErrorCategorySystem,
+	2: ErrorCategoryUser,
+	3: ErrorCategoryOther,
+}
+
+// Done reports whether the query has stopped, successfully or not.
+func (s QueryStatus) Done() bool {
+	switch s.State {
+	case QueryStateSucceeded, QueryStateFailed, QueryStateCancelled:
+		return true
+	}
+	return false
+}
+
+// Status fetches the query's current status.
+func (h *QueryHandle) Status(ctx context.Context) (QueryStatus, error) {
+	execution, err := h.conn.queryExecution(ctx, h.ID)
+	if err != nil {
+		return QueryStatus{}, err
+	}
+
+	return newQueryStatus(execution), nil
+}
+
+func newQueryStatus(execution *QueryExecution) QueryStatus {
+	status := QueryStatus{
+		State:             execution.Status.State,
+		StateChangeReason: execution.Status.StateChangeReason,
+		SubmittedAt:       execution.Status.SubmissionDateTime,
+		CompletedAt:       execution.Status.CompletionDateTime,
+		Stats:             newQueryStats(execution),
+	}
+
+	if athenaErr := execution.Status.AthenaError; athenaErr != nil {
+		status.ErrorCategory = errorCategories[athenaErr.ErrorCategory]
+		status.ErrorType = athenaErr.ErrorType
+		status.Retryable = athenaErr.Retryable
+	}
+
+	return status
+}
+
+// Wait blocks until the query finishes, returning an error if it failed.
+// If ctx is done first, Wait returns its error but the query keeps running.
+func (h *QueryHandle) Wait(ctx context.Context) error {
+	execution, err := h.conn.pollQuery(ctx, h.ID)
+	h.conn.logger.finished(ctx, h.ID, execution, err)
+	h.conn.hooksFor(ctx).complete(ctx, h.ID, execution, err)
+	return err
+}
+
+// Cancel stops the query.
+func (h *QueryHandle) Cancel(ctx context.Context) error {
+	return h.conn.athena.StopQueryExecution(ctx, &StopQueryExecutionInput{QueryID: h.ID})
+}
+
+// Results waits for the query to finish and opens its results.
+func (h *QueryHandle) Results(ctx context.Context) (*Results, error) {
+	if err := h.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	return (&Client{conn: h.conn}).OpenResults(ctx, h.ID)
+}
+
+// Results iterates over a query's results, converting values the same way
+// as the database/sql driver. Its usage mirrors sql.Rows.
+type Results struct {
+	rows    *rows
+	columns []string
+	values  []driver.Value
+	err     error
+}
+
+func newResults(r *rows) *Results {
+	columns := r.Columns()
+	return &Results{
+		rows:    r,
+		columns: columns,
+		values:  make([]driver.Value, len(columns)),
+	}
+}
+
+// QueryID returns the ID of the query the results are from.
+func (r *Results) QueryID() string {
+	return r.rows.QueryID()
+}
+
+// Columns returns the column names.
+func (r *Results) Columns() []string {
+	return r.columns
+}
+
+// ColumnInfo describes the columns, including the catalog, schema and table
+// each comes from.
+func (r *Results) ColumnInfo() []Column {
+	return r.rows.ColumnInfo()
+}
+
+// Next prepares the next row for Values() or Scan().
+// It returns false when there are no more rows or an error occurred.
+func (r *Results) Next() bool {
+	if r.err != nil {
+		return false
+	}
+
+	if err := r.rows.Next(r.values); err != nil {
+		if err != io.EOF {
+			r.err = err
+		}
+		return false
+	}
+
+	return true
+}
+
+// Values returns the current row.
+func (r *Results) Values() []interface{} {
+	values := make([]interface{}, len(r.values))
+	for i, v := range r.values {
+		values[i] = v
+	}
+	return values
+}
+
+// Scan copies the current row into dest, which must be pointers or
+// sql.Scanners. Numbers are converted to the type pointed to, e.g. bigint
+// into *int, unless they don't fit in it.
+func (r *Results) Scan(dest ...interface{}) error {
+	if len(dest) != len(r.values) {
+		return fmt.Errorf("expected %d destination arguments in Scan, not %d", len(r.values), len(dest))
+	}
+
+	for i, v := range r.values {
+		if err := assignValue(dest[i], v); err != nil {
+			return fmt.Errorf("column %s: %v", r.columns[i], err)
+		}
+	}
+
+	return nil
+}
+
+// Err returns the error, if any, that stopped iteration.
+func (r *Results) Err() error {
+	return r.err
+}
+
+// Close stops iteration.
+func (r *Results) Close() error {
+	return r.rows.Close()
+}
+
+// assignValue copies src into dest the way database/sql's Rows.Scan() does:
+// dest may be a sql.Scanner, and numbers are converted to its type, unless
+// that would overflow it or lose a fraction.
+func assignValue(dest interface{}, src driver.Value) error {
+	if scanner, ok := dest.(sql.Scanner); ok {
+		return scanner.Scan(src)
+	}
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("destination %T is not a non-nil pointer", dest)
+	}
+	dv = dv.Elem()
+
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return fmt.Errorf("cannot scan NULL into %T", dest)
+	}
+
+	sv := reflect.ValueOf(src)
+	switch {
+	case sv.Type().AssignableTo(dv.Type()):
+		dv.Set(sv)
+	case dv.Kind() == reflect.Ptr && sv.Type().AssignableTo(dv.Type().Elem()):
+		p := reflect.New(dv.Type().Elem())
+		p.Elem().Set(sv)
+		dv.Set(p)
+	case isNumber(sv.Kind()) && isNumber(dv.Kind()):
+		if err := convertNumber(dv, sv); err != nil {
+			return fmt.Errorf("cannot scan %v into %T: %w", src, dest, err)
+		}
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, dest)
+	}
+
+	return nil
+}
+
+var (
+	errOutOfRange = errors.New("value out of range")
+	errFraction   = errors.New("value has a fraction")
+)
+
+// convertNumber sets dv to sv, both numbers, unless dv can't hold it exactly.
+func convertNumber(dv, sv reflect.Value) error {
+	switch dv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch sv.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if sv.Uint() > math.MaxInt64 {
+				return errOutOfRange
+			}
+			n = int64(sv.Uint())
+		case reflect.Float32, reflect.Float64:
+			f := sv.Float()
+			if f != math.Trunc(f) {
+				return errFraction
+			}
+			if f < math.MinInt64 || f >= math.MaxInt64 {
+				return errOutOfRange
+			}
+			n = int64(f)
+		default:
+			n = sv.Int()
+		}
+		if dv.OverflowInt(n) {
+			return errOutOfRange
+		}
+		dv.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		switch sv.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = sv.Uint()
+		case reflect.Float32, reflect.Float64:
+			f := sv.Float()
+			if f != math.Trunc(f) {
+				return errFraction
+			}
+			if f < 0 || f >= math.MaxUint64 {
+				return errOutOfRange
+			}
+			n = uint64(f)
+		default:
+			if sv.Int() < 0 {
+				return errOutOfRange
+			}
+			n = uint64(sv.Int())
+		}
+		if dv.OverflowUint(n) {
+			return errOutOfRange
+		}
+		dv.SetUint(n)
+
+	default:
+		var f float64
+		switch sv.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f = float64(sv.Uint())
+		case reflect.Float32, reflect.Float64:
+			f = sv.Float()
+		default:
+			f = float64(sv.Int())
+		}
+		if dv.OverflowFloat(f) {
+			return errOutOfRange
+		}
+		dv.SetFloat(f)
+	}
+	return nil
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package athena
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockAsyncAthenaClient struct {
+	mockAthenaClient
+
+	mu         sync.Mutex
+	states     map[string][]string
+	statistics QueryExecutionStatistics
+	started    []*StartQueryExecutionInput
+	stopped    []string
+}
+
+func (m *mockAsyncAthenaClient) StartQueryExecution(_ context.Context, input *StartQueryExecutionInput) (*StartQueryExecutionOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = append(m.started, input)
+	return &StartQueryExecutionOutput{QueryID: input.Query}, nil
+}
+
+func (m *mockAsyncAthenaClient) GetQueryExecution(_ context.Context, input *GetQueryExecutionInput) (*QueryExecution, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queryID := input.QueryID
+	states := m.states[queryID]
+	state := states[0]
+	if len(states) > 1 {
+		m.states[queryID] = states[1:]
+	}
+
+	statementType := StatementTypeDML
+	if queryID == "show" {
+		statementType = StatementTypeUtility
+	}
+
+	return &QueryExecution{
+		QueryID:       queryID,
+		StatementType: statementType,
+		Statistics:    m.statistics,
+		Status: QueryExecutionStatus{
+			State:             state,
+			StateChangeReason: "reason",
+		},
+	}, nil
+}
+
+func (m *mockAsyncAthenaClient) StopQueryExecution(_ context.Context, input *StopQueryExecutionInput) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopped = append(m.stopped, input.QueryID)
+	m.states[input.QueryID] = []string{QueryStateCancelled}
+	return nil
+}
+
+func newMockClient(mock *mockAsyncAthenaClient) *Client {
+	return &Client{conn: &conn{athena: mock, pollFrequency: time.Millisecond}}
+}
+
+func TestClient_StartQuery(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"select": {
+			QueryStateQueued,
+			QueryStateRunning,
+			QueryStateSucceeded,
+		},
+	}}
+	client := newMockClient(mock)
+	ctx := context.Background()
+
+	handle, err := client.StartQuery(ctx, "select")
+	require.NoError(t, err)
+	assert.Equal(t, "select", handle.ID)
+
+	status, err := handle.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, QueryStateQueued, status.State)
+	assert.False(t, status.Done())
+
+	results, err := handle.Results(ctx)
+	require.NoError(t, err)
+	defer results.Close()
+
+	assert.Equal(t, []string{"first_name", "last_name"}, results.Columns())
+	cnt := 0
+	for results.Next() {
+		var firstName, lastName string
+		require.NoError(t, results.Scan(&firstName, &lastName))
+		assert.NotEmpty(t, firstName)
+		cnt++
+	}
+	assert.NoError(t, results.Err())
+	assert.Equal(t, 9, cnt)
+}
+
+func TestClient_OpenResults(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"show":    {QueryStateSucceeded},
+		"running": {QueryStateRunning},
+	}}
+	client := newMockClient(mock)
+	ctx := context.Background()
+
+	results, err := client.OpenResults(ctx, "show")
+	require.NoError(t, err)
+	cnt := 0
+	for results.Next() {
+		cnt++
+	}
+	assert.NoError(t, results.Err())
+	assert.Equal(t, 2, cnt, "utility statements have no header row")
+	assert.Equal(t, []Column{{Name: "partition", Type: "varchar", CatalogName: "hive"}}, results.ColumnInfo())
+
+	_, err = client.OpenResults(ctx, "running")
+	assert.Error(t, err)
+}
+
+func TestQueryHandle_Wait(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"failed":  {QueryStateFailed},
+		"running": {QueryStateRunning},
+	}}
+	client := newMockClient(mock)
+
+	err := client.Attach("failed").Wait(context.Background())
+	assert.EqualError(t, err, "reason")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = client.Attach("running").Wait(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Empty(t, mock.stopped, "Wait shouldn't stop the query")
+
+	require.NoError(t, client.Attach("running").Cancel(context.Background()))
+	assert.Equal(t, []string{"running"}, mock.stopped)
+}
+
+func TestAssignValue(t *testing.T) {
+	var i int
+	require.NoError(t, assignValue(&i, int64(42)))
+	assert.Equal(t, 42, i)
+
+	var f float32
+	require.NoError(t, assignValue(&f, 1.5))
+	assert.Equal(t, float32(1.5), f)
+
+	var s *string
+	require.NoError(t, assignValue(&s, "foo"))
+	assert.Equal(t, "foo", *s)
+	require.NoError(t, assignValue(&s, nil))
+	assert.Nil(t, s)
+
+	assert.Error(t, assignValue(&i, "foo"))
+	assert.Error(t, assignValue(&i, nil))
+	assert.Error(t, assignValue(i, int64(1)))
+
+	// Numbers only convert if they fit.
+	require.NoError(t, assignValue(&i, 2.0))
+	assert.Equal(t, 2, i)
+	assert.ErrorIs(t, assignValue(&i, 2.5), errFraction)
+	var i8 int8
+	require.NoError(t, assignValue(&i8, int64(-128)))
+	assert.Equal(t, int8(-128), i8)
+	assert.ErrorIs(t, assignValue(&i8, int64(300)), errOutOfRange)
+	var u uint
+	assert.ErrorIs(t, assignValue(&u, int64(-1)), errOutOfRange)
+	assert.ErrorIs(t, assignValue(&f, math.MaxFloat64), errOutOfRange)
+
+	// Scanners scan the value themselves.
+	var ni sql.NullInt64
+	require.NoError(t, assignValue(&ni, int64(7)))
+	assert.Equal(t, sql.NullInt64{Int64: 7, Valid: true}, ni)
+	require.NoError(t, assignValue(&ni, nil))
+	assert.False(t, ni.Valid)
+	var ns sql.NullString
+	require.NoError(t, assignValue(&ns, "foo"))
+	assert.Equal(t, sql.NullString{String: "foo", Valid: true}, ns)
+}
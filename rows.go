@@ -1,27 +1,60 @@
 package athena
 
 import (
+	"context"
 	"database/sql/driver"
 	"io"
-
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/athena"
-	"github.com/aws/aws-sdk-go/service/athena/athenaiface"
+	"time"
 )
 
 type rows struct {
-	athena  athenaiface.AthenaAPI
+	athena  AthenaAPI
 	queryID string
 
+	ctx   context.Context
+	hooks Hooks
+	info  QueryInfo
+	pages int
+	read  int
+
 	done          bool
+	closed        bool
 	skipHeaderRow bool
-	out           *athena.GetQueryResultsOutput
+	out           *GetQueryResultsOutput
+
+	pageSize      int
+	maxRows       int64
+	stopAtMaxRows bool
+	// truncated is set once rows were left unread because of maxRows.
+	truncated bool
+
+	// prefetch is set while pages are fetched ahead of being read.
+	prefetch *prefetcher
 }
 
 type rowsConfig struct {
-	Athena     athenaiface.AthenaAPI
+	Athena     AthenaAPI
 	QueryID    string
 	SkipHeader bool
+
+	// Prefetch is how many pages to fetch ahead of the one being read, in
+	// the background. None are if it's 0.
+	Prefetch int
+
+	// PageSize is how many rows to fetch per page, including the header row.
+	// Athena's default and maximum is 1000.
+	PageSize int
+
+	// MaxRows, if set, is the most rows that can be read. Reading more
+	// returns an *ErrRowLimitExceeded, or io.EOF if StopAtMaxRows is set.
+	// Pages past the limit aren't fetched.
+	MaxRows       int64
+	StopAtMaxRows bool
+
+	// Context is passed to Hooks, along with Info.
+	Context context.Context
+	Hooks   Hooks
+	Info    QueryInfo
 }
 
 func newRows(cfg rowsConfig) (*rows, error) {
@@ -29,94 +62,217 @@ func newRows(cfg rowsConfig) (*rows, error) {
 		athena:        cfg.Athena,
 		queryID:       cfg.QueryID,
 		skipHeaderRow: cfg.SkipHeader,
+		pageSize:      cfg.PageSize,
+		maxRows:       cfg.MaxRows,
+		stopAtMaxRows: cfg.StopAtMaxRows,
+		ctx:           cfg.Context,
+		hooks:         cfg.Hooks,
+		info:          cfg.Info,
+	}
+	if r.ctx == nil {
+		r.ctx = context.Background()
 	}
 
-	shouldContinue, err := r.fetchNextPage(nil)
+	shouldContinue, err := r.fetchNextPage("")
 	if err != nil {
 		return nil, err
 	}
 
 	r.done = !shouldContinue
+	if !r.done && cfg.Prefetch > 0 && hasNextPage(r.out) {
+		var maxRows int64
+		if r.maxRows > 0 {
+			maxRows = r.maxRows - int64(len(r.out.Rows))
+		}
+		if r.maxRows == 0 || maxRows > 0 {
+			r.prefetch = startPrefetch(r.ctx, cfg.Prefetch, maxRows, r.getPage, r.out.NextToken)
+		}
+	}
 	return &r, nil
 }
 
+// QueryID returns the ID of the query the rows are the results of.
+func (r *rows) QueryID() string {
+	return r.queryID
+}
+
 func (r *rows) Columns() []string {
 	var columns []string
-	for _, colInfo := range r.out.ResultSet.ResultSetMetadata.ColumnInfo {
-		columns = append(columns, *colInfo.Name)
+	for _, colInfo := range r.out.Columns {
+		columns = append(columns, colInfo.Name)
 	}
 
 	return columns
 }
 
-func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
-	colInfo := r.out.ResultSet.ResultSetMetadata.ColumnInfo[index]
-	if colInfo.Type != nil {
-		return *colInfo.Type
+// Column describes a column of a query's results.
+type Column struct {
+	Name string
+	Type string
+
+	// CatalogName, SchemaName and TableName say where the column's values
+	// come from, when Athena knows. For a federated query, CatalogName is the
+	// data catalog it reads from.
+	CatalogName string
+	SchemaName  string
+	TableName   string
+}
+
+// ColumnInfo describes the columns of the results.
+func (r *rows) ColumnInfo() []Column {
+	var columns []Column
+	for _, colInfo := range r.out.Columns {
+		columns = append(columns, Column{
+			Name:        colInfo.Name,
+			Type:        colInfo.Type,
+			CatalogName: colInfo.CatalogName,
+			SchemaName:  colInfo.SchemaName,
+			TableName:   colInfo.TableName,
+		})
 	}
-	return ""
+
+	return columns
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.out.Columns[index].Type
 }
 
 func (r *rows) Next(dest []driver.Value) error {
+	cur, err := r.nextRow()
+	if err != nil {
+		return err
+	}
+
+	return convertRow(r.out.Columns, cur, dest)
+}
+
+// nextRow shifts to the next row, fetching the next page if needed.
+func (r *rows) nextRow() ([]*string, error) {
 	if r.done {
-		return io.EOF
+		return nil, io.EOF
+	}
+	if r.maxRows > 0 && int64(r.read) >= r.maxRows {
+		return nil, r.rowLimitReached()
 	}
 
-	// If nothing left to iterate...
-	if len(r.out.ResultSet.Rows) == 0 {
+	// While nothing left to iterate, as pages can be empty, e.g. a first page
+	// holding only the header row...
+	for len(r.out.Rows) == 0 {
 		// And if nothing more to paginate...
-		if r.out.NextToken == nil || *r.out.NextToken == "" {
-			return io.EOF
+		if !hasNextPage(r.out) {
+			return nil, io.EOF
 		}
 
-		cont, err := r.fetchNextPage(r.out.NextToken)
+		var cont bool
+		var err error
+		if r.prefetch != nil {
+			cont, err = r.usePage(r.prefetch.next())
+		} else {
+			cont, err = r.fetchNextPage(r.out.NextToken)
+		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if !cont {
-			return io.EOF
+			return nil, io.EOF
 		}
 	}
 
-	// Shift to next row
-	cur := r.out.ResultSet.Rows[0]
-	columns := r.out.ResultSet.ResultSetMetadata.ColumnInfo
-	if err := convertRow(columns, cur.Data, dest); err != nil {
-		return err
+	cur := r.out.Rows[0]
+	r.out.Rows = r.out.Rows[1:]
+	r.read++
+	return cur, nil
+}
+
+// rowLimitReached returns what reading a row past maxRows does. Whether there
+// are more rows is told from the page being read, without fetching another.
+func (r *rows) rowLimitReached() error {
+	if len(r.out.Rows) == 0 && !hasNextPage(r.out) {
+		return io.EOF
+	}
+	if r.stopAtMaxRows {
+		r.truncated = true
+		return io.EOF
 	}
+	return &ErrRowLimitExceeded{QueryID: r.queryID, MaxRows: r.maxRows}
+}
 
-	r.out.ResultSet.Rows = r.out.ResultSet.Rows[1:]
-	return nil
+func hasNextPage(out *GetQueryResultsOutput) bool {
+	return out.NextToken != ""
 }
 
-func (r *rows) fetchNextPage(token *string) (bool, error) {
-	var err error
-	r.out, err = r.athena.GetQueryResults(&athena.GetQueryResultsInput{
-		QueryExecutionId: aws.String(r.queryID),
-		NextToken:        token,
-	})
-	if err != nil {
-		return false, err
+// fetchedPage is the outcome of fetching a page of results.
+type fetchedPage struct {
+	out *GetQueryResultsOutput
+	// info is missing the page's Number and Rows until it's used.
+	info PageInfo
+}
+
+// getPage fetches the page of results after token, or the first if it's nil.
+// It's safe to call while the rows are being read.
+func (r *rows) getPage(ctx context.Context, token string) fetchedPage {
+	input := &GetQueryResultsInput{
+		QueryID:    r.queryID,
+		NextToken:  token,
+		MaxResults: r.pageSize,
 	}
 
+	page := fetchedPage{info: PageInfo{StartedAt: time.Now()}}
+	page.out, page.info.Err = r.athena.GetQueryResults(ctx, input)
+	page.info.Duration = time.Since(page.info.StartedAt)
+	return page
+}
+
+func (r *rows) fetchNextPage(token string) (bool, error) {
+	return r.usePage(r.getPage(r.ctx, token))
+}
+
+// usePage makes page the one being read.
+func (r *rows) usePage(fetched fetchedPage) (bool, error) {
+	r.pages++
+	page := fetched.info
+	page.Number = r.pages
+	if page.Err != nil {
+		r.hooks.page(r.ctx, r.info, page)
+		return false, page.Err
+	}
+	r.out = fetched.out
+
 	var rowOffset = 0
 	// First row of the first page contains header if the query is not DDL.
-	// These are also available in *athena.Row.ResultSetMetadata.
+	// They're also in the page's Columns.
 	if r.skipHeaderRow {
 		rowOffset = 1
 		r.skipHeaderRow = false
 	}
 
-	if len(r.out.ResultSet.Rows) < rowOffset+1 {
-		return false, nil
+	page.Rows = len(r.out.Rows) - rowOffset
+	if page.Rows < 0 {
+		page.Rows = 0
+	}
+	r.hooks.page(r.ctx, r.info, page)
+
+	if len(r.out.Rows) <= rowOffset {
+		// Rows may still be on the next pages.
+		r.out.Rows = nil
+		return hasNextPage(r.out), nil
 	}
 
-	r.out.ResultSet.Rows = r.out.ResultSet.Rows[rowOffset:]
+	r.out.Rows = r.out.Rows[rowOffset:]
 	return true, nil
 }
 
 func (r *rows) Close() error {
 	r.done = true
+	if r.prefetch != nil {
+		r.prefetch.stop()
+		r.prefetch = nil
+	}
+	if !r.closed {
+		r.closed = true
+		r.hooks.close(r.ctx, r.info, r.read)
+	}
 	return nil
 }
@@ -1,48 +1,37 @@
 package athena
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
 	"io"
 	"math/rand"
+	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 
-	"github.com/aws/aws-sdk-go/service/athena"
-	"github.com/aws/aws-sdk-go/service/athena/athenaiface"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var dummyError = errors.New("dummy error")
 
-type genQueryResultsOutputByToken func(token string) (*athena.GetQueryResultsOutput, error)
+type genQueryResultsOutputByToken func(token string) (*GetQueryResultsOutput, error)
 
 var queryToResultsGenMap = map[string]genQueryResultsOutputByToken{
 	"select":         dummySelectQueryResponse,
 	"show":           dummyShowResponse,
+	"insert":         dummyInsertResponse,
 	"iteration_fail": dummyFailedIterationResponse,
 }
 
-func genColumnInfo(column string) *athena.ColumnInfo {
-	caseSensitive := true
-	catalogName := "hive"
-	nullable := "UNKNOWN"
-	precision := int64(2147483647)
-	scale := int64(0)
-	schemaName := ""
-	tableName := ""
-	columnType := "varchar"
-
-	return &athena.ColumnInfo{
-		CaseSensitive: &caseSensitive,
-		CatalogName:   &catalogName,
-		Nullable:      &nullable,
-		Precision:     &precision,
-		Scale:         &scale,
-		SchemaName:    &schemaName,
-		TableName:     &tableName,
-		Type:          &columnType,
-		Label:         &column,
-		Name:          &column,
+func genColumnInfo(column string) ColumnInfo {
+	return ColumnInfo{
+		Name:        column,
+		Type:        "varchar",
+		CatalogName: "hive",
+		Precision:   2147483647,
 	}
 }
 
@@ -55,65 +44,49 @@ func randomString() string {
 	return string(s)
 }
 
-func genRow(isHeader bool, columns []*athena.ColumnInfo) *athena.Row {
-	var data []*athena.Datum
+func genRow(isHeader bool, columns []ColumnInfo) []*string {
+	var row []*string
 	for i := 0; i < len(columns); i++ {
-		if isHeader {
-			data = append(data, &athena.Datum{
-				VarCharValue: columns[i].Name,
-			})
-		} else {
-			s := randomString()
-			data = append(data, &athena.Datum{
-				VarCharValue: &s,
-			})
+		s := columns[i].Name
+		if !isHeader {
+			s = randomString()
 		}
+		row = append(row, &s)
 	}
-	return &athena.Row{
-		Data: data,
-	}
+	return row
 }
 
-func dummySelectQueryResponse(token string) (*athena.GetQueryResultsOutput, error) {
+func dummySelectQueryResponse(token string) (*GetQueryResultsOutput, error) {
 	switch token {
 	case "":
-		var nextToken = "page_1"
-		columns := []*athena.ColumnInfo{
+		columns := []ColumnInfo{
 			genColumnInfo("first_name"),
 			genColumnInfo("last_name"),
 		}
-		return &athena.GetQueryResultsOutput{
-			NextToken: &nextToken,
-			ResultSet: &athena.ResultSet{
-				ResultSetMetadata: &athena.ResultSetMetadata{
-					ColumnInfo: columns,
-				},
-				Rows: []*athena.Row{
-					genRow(true, columns),
-					genRow(false, columns),
-					genRow(false, columns),
-					genRow(false, columns),
-					genRow(false, columns),
-				},
+		return &GetQueryResultsOutput{
+			NextToken: "page_1",
+			Columns:   columns,
+			Rows: [][]*string{
+				genRow(true, columns),
+				genRow(false, columns),
+				genRow(false, columns),
+				genRow(false, columns),
+				genRow(false, columns),
 			},
 		}, nil
 	case "page_1":
-		columns := []*athena.ColumnInfo{
+		columns := []ColumnInfo{
 			genColumnInfo("first_name"),
 			genColumnInfo("last_name"),
 		}
-		return &athena.GetQueryResultsOutput{
-			ResultSet: &athena.ResultSet{
-				ResultSetMetadata: &athena.ResultSetMetadata{
-					ColumnInfo: columns,
-				},
-				Rows: []*athena.Row{
-					genRow(false, columns),
-					genRow(false, columns),
-					genRow(false, columns),
-					genRow(false, columns),
-					genRow(false, columns),
-				},
+		return &GetQueryResultsOutput{
+			Columns: columns,
+			Rows: [][]*string{
+				genRow(false, columns),
+				genRow(false, columns),
+				genRow(false, columns),
+				genRow(false, columns),
+				genRow(false, columns),
 			},
 		}, nil
 	default:
@@ -121,44 +94,39 @@ func dummySelectQueryResponse(token string) (*athena.GetQueryResultsOutput, erro
 	}
 }
 
-func dummyShowResponse(_ string) (*athena.GetQueryResultsOutput, error) {
-	columns := []*athena.ColumnInfo{
+func dummyShowResponse(_ string) (*GetQueryResultsOutput, error) {
+	columns := []ColumnInfo{
 		genColumnInfo("partition"),
 	}
-	return &athena.GetQueryResultsOutput{
-		ResultSet: &athena.ResultSet{
-			ResultSetMetadata: &athena.ResultSetMetadata{
-				ColumnInfo: columns,
-			},
-			Rows: []*athena.Row{
-				genRow(false, columns),
-				genRow(false, columns),
-			},
+	return &GetQueryResultsOutput{
+		Columns: columns,
+		Rows: [][]*string{
+			genRow(false, columns),
+			genRow(false, columns),
 		},
 	}, nil
 }
 
-func dummyFailedIterationResponse(token string) (*athena.GetQueryResultsOutput, error) {
+func dummyInsertResponse(_ string) (*GetQueryResultsOutput, error) {
+	return &GetQueryResultsOutput{}, nil
+}
+
+func dummyFailedIterationResponse(token string) (*GetQueryResultsOutput, error) {
 	switch token {
 	case "":
-		var nextToken = "page_1"
-		columns := []*athena.ColumnInfo{
+		columns := []ColumnInfo{
 			genColumnInfo("first_name"),
 			genColumnInfo("last_name"),
 		}
-		return &athena.GetQueryResultsOutput{
-			NextToken: &nextToken,
-			ResultSet: &athena.ResultSet{
-				ResultSetMetadata: &athena.ResultSetMetadata{
-					ColumnInfo: columns,
-				},
-				Rows: []*athena.Row{
-					genRow(true, columns),
-					genRow(false, columns),
-					genRow(false, columns),
-					genRow(false, columns),
-					genRow(false, columns),
-				},
+		return &GetQueryResultsOutput{
+			NextToken: "page_1",
+			Columns:   columns,
+			Rows: [][]*string{
+				genRow(true, columns),
+				genRow(false, columns),
+				genRow(false, columns),
+				genRow(false, columns),
+				genRow(false, columns),
 			},
 		}, nil
 	default:
@@ -167,15 +135,11 @@ func dummyFailedIterationResponse(token string) (*athena.GetQueryResultsOutput,
 }
 
 type mockAthenaClient struct {
-	athenaiface.AthenaAPI
+	AthenaAPI
 }
 
-func (m *mockAthenaClient) GetQueryResults(query *athena.GetQueryResultsInput) (*athena.GetQueryResultsOutput, error) {
-	var nextToken = ""
-	if query.NextToken != nil {
-		nextToken = *query.NextToken
-	}
-	return queryToResultsGenMap[*query.QueryExecutionId](nextToken)
+func (m *mockAthenaClient) GetQueryResults(_ context.Context, query *GetQueryResultsInput) (*GetQueryResultsOutput, error) {
+	return queryToResultsGenMap[query.QueryID](query.NextToken)
 }
 
 func castToValue(dest ...driver.Value) []driver.Value {
@@ -212,26 +176,190 @@ func TestRows_Next(t *testing.T) {
 		},
 	}
 	for _, test := range tests {
-		r, _ := newRows(rowsConfig{
-			Athena:     new(mockAthenaClient),
-			QueryID:    test.queryID,
-			SkipHeader: test.skipHeader,
+		for _, prefetch := range []int{0, 1, 3} {
+			testRowsNext(t, test.queryID, test.skipHeader, prefetch, test.expectedResultsSize, test.expectedError)
+		}
+	}
+}
+
+func testRowsNext(t *testing.T, queryID string, skipHeader bool, prefetch int, expectedResultsSize int, expectedError error) {
+	r, _ := newRows(rowsConfig{
+		Athena:     new(mockAthenaClient),
+		QueryID:    queryID,
+		SkipHeader: skipHeader,
+		Prefetch:   prefetch,
+	})
+	defer r.Close()
+
+	var firstName, lastName string
+	cnt := 0
+	for {
+		err := r.Next(castToValue(&firstName, &lastName))
+		if err != nil {
+			if err != io.EOF {
+				assert.Equal(t, expectedError, err)
+			}
+			break
+		}
+		cnt++
+	}
+	if expectedError == nil {
+		assert.Equal(t, expectedResultsSize, cnt)
+	}
+}
+
+// endlessAthenaClient returns pages of a single row without end.
+type endlessAthenaClient struct {
+	AthenaAPI
+	calls      atomic.Int32
+	maxResults atomic.Int64
+}
+
+func (m *endlessAthenaClient) GetQueryResults(ctx context.Context, query *GetQueryResultsInput) (*GetQueryResultsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.calls.Add(1)
+	m.maxResults.Store(int64(query.MaxResults))
+	columns := []ColumnInfo{genColumnInfo("name")}
+	return &GetQueryResultsOutput{
+		NextToken: randomString(),
+		Columns:   columns,
+		Rows:      [][]*string{genRow(false, columns)},
+	}, nil
+}
+
+func TestRows_Prefetch(t *testing.T) {
+	client := new(endlessAthenaClient)
+	r, err := newRows(rowsConfig{
+		Athena:   client,
+		QueryID:  "endless",
+		Prefetch: 2,
+	})
+	require.NoError(t, err)
+
+	var name string
+	require.NoError(t, r.Next(castToValue(&name)))
+	require.NoError(t, r.Next(castToValue(&name)))
+
+	// The page being read and the 2 after it.
+	assert.Eventually(t, func() bool { return client.calls.Load() == 4 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	assert.EqualValues(t, 4, client.calls.Load())
+
+	require.NoError(t, r.Close())
+	calls := client.calls.Load()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, calls, client.calls.Load())
+	assert.Equal(t, io.EOF, r.Next(castToValue(&name)))
+}
+
+func TestRows_MaxRows(t *testing.T) {
+	for _, prefetch := range []int{0, 2} {
+		client := new(endlessAthenaClient)
+		r, err := newRows(rowsConfig{
+			Athena:   client,
+			QueryID:  "endless",
+			Prefetch: prefetch,
+			PageSize: 1,
+			MaxRows:  3,
 		})
+		require.NoError(t, err)
+
+		var name string
+		for i := 0; i < 3; i++ {
+			require.NoError(t, r.Next(castToValue(&name)))
+		}
+		err = r.Next(castToValue(&name))
+		assert.Equal(t, &ErrRowLimitExceeded{QueryID: "endless", MaxRows: 3}, err)
+		require.NoError(t, r.Close())
+		assert.EqualValues(t, 3, client.calls.Load(), "pages past the limit were fetched")
+		assert.EqualValues(t, 1, client.maxResults.Load())
+	}
 
-		var firstName, lastName string
-		cnt := 0
-		for {
-			err := r.Next(castToValue(&firstName, &lastName))
-			if err != nil {
-				if err != io.EOF {
-					assert.Equal(t, test.expectedError, err)
+	r, err := newRows(rowsConfig{
+		Athena:        new(endlessAthenaClient),
+		QueryID:       "endless",
+		MaxRows:       2,
+		StopAtMaxRows: true,
+	})
+	require.NoError(t, err)
+	var name string
+	require.NoError(t, r.Next(castToValue(&name)))
+	require.NoError(t, r.Next(castToValue(&name)))
+	assert.Equal(t, io.EOF, r.Next(castToValue(&name)))
+	assert.True(t, r.truncated)
+
+	// Results that fit within the limit are read as usual.
+	testRowsNext(t, "select", true, 0, 9, nil)
+	r, err = newRows(rowsConfig{
+		Athena:     new(mockAthenaClient),
+		QueryID:    "select",
+		SkipHeader: true,
+		MaxRows:    9,
+	})
+	require.NoError(t, err)
+	var firstName, lastName string
+	for i := 0; i < 9; i++ {
+		require.NoError(t, r.Next(castToValue(&firstName, &lastName)))
+	}
+	assert.Equal(t, io.EOF, r.Next(castToValue(&firstName, &lastName)))
+}
+
+// pagingAthenaClient pages through a header row followed by rows rows,
+// honouring MaxResults like Athena does.
+type pagingAthenaClient struct {
+	AthenaAPI
+	rows int
+}
+
+func (m *pagingAthenaClient) GetQueryResults(_ context.Context, query *GetQueryResultsInput) (*GetQueryResultsOutput, error) {
+	offset := 0
+	if query.NextToken != "" {
+		offset, _ = strconv.Atoi(query.NextToken)
+	}
+	pageSize := 1000
+	if query.MaxResults > 0 {
+		pageSize = query.MaxResults
+	}
+
+	columns := []ColumnInfo{genColumnInfo("name")}
+	out := &GetQueryResultsOutput{Columns: columns}
+	for i := offset; i < offset+pageSize && i <= m.rows; i++ {
+		out.Rows = append(out.Rows, genRow(i == 0, columns))
+	}
+	if offset+pageSize <= m.rows {
+		out.NextToken = strconv.Itoa(offset + pageSize)
+	}
+	return out, nil
+}
+
+func TestRows_PageSize(t *testing.T) {
+	for _, pageSize := range []int{1, 2, 3, 1000} {
+		for _, prefetch := range []int{0, 2} {
+			for _, n := range []int{0, 1, 5} {
+				r, err := newRows(rowsConfig{
+					Athena:     &pagingAthenaClient{rows: n},
+					QueryID:    "select",
+					SkipHeader: true,
+					PageSize:   pageSize,
+					Prefetch:   prefetch,
+				})
+				require.NoError(t, err)
+
+				var name string
+				cnt := 0
+				for {
+					err := r.Next(castToValue(&name))
+					if err == io.EOF {
+						break
+					}
+					require.NoError(t, err)
+					cnt++
 				}
-				break
+				assert.Equal(t, n, cnt, "page size %d, prefetch %d", pageSize, prefetch)
+				require.NoError(t, r.Close())
 			}
-			cnt++
-		}
-		if test.expectedError == nil {
-			assert.Equal(t, test.expectedResultsSize, cnt)
 		}
 	}
 }
@@ -0,0 +1,339 @@
+package athena
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/corehandlers"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/athena"
+	"github.com/aws/aws-sdk-go/service/athena/athenaiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// newSessionAPI returns the AthenaAPI the driver uses when Config.API isn't
+// set, calling endpoint if it isn't empty.
+func newSessionAPI(sess *session.Session, endpoint string, logger *queryLogger) AthenaAPI {
+	var cfg aws.Config
+	if endpoint != "" {
+		cfg.Endpoint = aws.String(endpoint)
+	}
+	client := athena.New(sess, &cfg)
+	logger.logRetries(client)
+	return NewV1API(client)
+}
+
+// NewV1API returns an AthenaAPI that calls Athena with an AWS SDK for Go v1
+// client, e.g. athena.New(session).
+func NewV1API(client athenaiface.AthenaAPI) AthenaAPI {
+	return v1API{client: client}
+}
+
+type v1API struct {
+	client athenaiface.AthenaAPI
+}
+
+func (a v1API) StartQueryExecution(ctx context.Context, input *StartQueryExecutionInput) (*StartQueryExecutionOutput, error) {
+	in := &athena.StartQueryExecutionInput{
+		QueryString: aws.String(input.Query),
+		QueryExecutionContext: &athena.QueryExecutionContext{
+			Database: aws.String(input.Database),
+		},
+		ResultConfiguration: &athena.ResultConfiguration{
+			OutputLocation: aws.String(input.OutputLocation),
+		},
+	}
+	if input.Catalog != "" {
+		in.QueryExecutionContext.Catalog = aws.String(input.Catalog)
+	}
+	if input.WorkGroup != "" {
+		in.WorkGroup = aws.String(input.WorkGroup)
+	}
+	if input.ExpectedBucketOwner != "" {
+		in.ResultConfiguration.ExpectedBucketOwner = aws.String(input.ExpectedBucketOwner)
+	}
+	if input.S3ACLOption != "" {
+		in.ResultConfiguration.AclConfiguration = &athena.AclConfiguration{
+			S3AclOption: aws.String(input.S3ACLOption),
+		}
+	}
+	if enc := input.Encryption; enc != nil {
+		in.ResultConfiguration.EncryptionConfiguration = &athena.EncryptionConfiguration{
+			EncryptionOption: aws.String(enc.Option),
+		}
+		if enc.KMSKey != "" {
+			in.ResultConfiguration.EncryptionConfiguration.KmsKey = aws.String(enc.KMSKey)
+		}
+	}
+	if input.ClientRequestToken != "" {
+		in.ClientRequestToken = aws.String(input.ClientRequestToken)
+	}
+	if input.ResultReuseMaxAge > 0 {
+		in.ResultReuseConfiguration = &athena.ResultReuseConfiguration{
+			ResultReuseByAgeConfiguration: &athena.ResultReuseByAgeConfiguration{
+				Enabled:         aws.Bool(true),
+				MaxAgeInMinutes: aws.Int64(int64(input.ResultReuseMaxAge / time.Minute)),
+			},
+		}
+	}
+
+	out, err := a.client.StartQueryExecutionWithContext(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return &StartQueryExecutionOutput{QueryID: aws.StringValue(out.QueryExecutionId)}, nil
+}
+
+func (a v1API) StopQueryExecution(ctx context.Context, input *StopQueryExecutionInput) error {
+	_, err := a.client.StopQueryExecutionWithContext(ctx, &athena.StopQueryExecutionInput{
+		QueryExecutionId: aws.String(input.QueryID),
+	})
+	return err
+}
+
+func (a v1API) GetQueryExecution(ctx context.Context, input *GetQueryExecutionInput) (*QueryExecution, error) {
+	out, err := a.client.GetQueryExecutionWithContext(ctx, &athena.GetQueryExecutionInput{
+		QueryExecutionId: aws.String(input.QueryID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromV1QueryExecution(out.QueryExecution), nil
+}
+
+func (a v1API) BatchGetQueryExecution(ctx context.Context, input *BatchGetQueryExecutionInput) (*BatchGetQueryExecutionOutput, error) {
+	out, err := a.client.BatchGetQueryExecutionWithContext(ctx, &athena.BatchGetQueryExecutionInput{
+		QueryExecutionIds: aws.StringSlice(input.QueryIDs),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result BatchGetQueryExecutionOutput
+	for _, execution := range out.QueryExecutions {
+		result.QueryExecutions = append(result.QueryExecutions, fromV1QueryExecution(execution))
+	}
+	for _, unprocessed := range out.UnprocessedQueryExecutionIds {
+		result.Unprocessed = append(result.Unprocessed, UnprocessedQueryExecution{
+			QueryID:      aws.StringValue(unprocessed.QueryExecutionId),
+			ErrorCode:    aws.StringValue(unprocessed.ErrorCode),
+			ErrorMessage: aws.StringValue(unprocessed.ErrorMessage),
+		})
+	}
+	return &result, nil
+}
+
+func (a v1API) GetQueryResults(ctx context.Context, input *GetQueryResultsInput) (*GetQueryResultsOutput, error) {
+	in := &athena.GetQueryResultsInput{
+		QueryExecutionId: aws.String(input.QueryID),
+	}
+	if input.NextToken != "" {
+		in.NextToken = aws.String(input.NextToken)
+	}
+	if input.MaxResults > 0 {
+		in.MaxResults = aws.Int64(int64(input.MaxResults))
+	}
+
+	out, err := a.client.GetQueryResultsWithContext(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return fromV1QueryResults(out), nil
+}
+
+func fromV1QueryExecution(qe *athena.QueryExecution) *QueryExecution {
+	if qe == nil {
+		return nil
+	}
+
+	result := &QueryExecution{
+		QueryID:       aws.StringValue(qe.QueryExecutionId),
+		Query:         aws.StringValue(qe.Query),
+		StatementType: aws.StringValue(qe.StatementType),
+		WorkGroup:     aws.StringValue(qe.WorkGroup),
+	}
+	if qec := qe.QueryExecutionContext; qec != nil {
+		result.Database = aws.StringValue(qec.Database)
+	}
+	if rc := qe.ResultConfiguration; rc != nil {
+		result.OutputLocation = aws.StringValue(rc.OutputLocation)
+	}
+	if status := qe.Status; status != nil {
+		result.Status = QueryExecutionStatus{
+			State:              aws.StringValue(status.State),
+			StateChangeReason:  aws.StringValue(status.StateChangeReason),
+			SubmissionDateTime: aws.TimeValue(status.SubmissionDateTime),
+			CompletionDateTime: aws.TimeValue(status.CompletionDateTime),
+		}
+		if athenaErr := status.AthenaError; athenaErr != nil {
+			result.Status.AthenaError = &AthenaError{
+				ErrorCategory: aws.Int64Value(athenaErr.ErrorCategory),
+				ErrorType:     aws.Int64Value(athenaErr.ErrorType),
+				ErrorMessage:  aws.StringValue(athenaErr.ErrorMessage),
+				Retryable:     aws.BoolValue(athenaErr.Retryable),
+			}
+		}
+	}
+	if stats := qe.Statistics; stats != nil {
+		result.Statistics = QueryExecutionStatistics{
+			DataScannedInBytes:    aws.Int64Value(stats.DataScannedInBytes),
+			QueryQueueTime:        millis(aws.Int64Value(stats.QueryQueueTimeInMillis)),
+			QueryPlanningTime:     millis(aws.Int64Value(stats.QueryPlanningTimeInMillis)),
+			EngineExecutionTime:   millis(aws.Int64Value(stats.EngineExecutionTimeInMillis)),
+			ServiceProcessingTime: millis(aws.Int64Value(stats.ServiceProcessingTimeInMillis)),
+			TotalExecutionTime:    millis(aws.Int64Value(stats.TotalExecutionTimeInMillis)),
+			DataManifestLocation:  aws.StringValue(stats.DataManifestLocation),
+		}
+		if reuse := stats.ResultReuseInformation; reuse != nil {
+			result.Statistics.ResultReused = aws.BoolValue(reuse.ReusedPreviousResult)
+		}
+	}
+	return result
+}
+
+func fromV1QueryResults(out *athena.GetQueryResultsOutput) *GetQueryResultsOutput {
+	result := &GetQueryResultsOutput{NextToken: aws.StringValue(out.NextToken)}
+	if out.ResultSet == nil {
+		return result
+	}
+	if metadata := out.ResultSet.ResultSetMetadata; metadata != nil {
+		for _, col := range metadata.ColumnInfo {
+			result.Columns = append(result.Columns, ColumnInfo{
+				Name:        aws.StringValue(col.Name),
+				Type:        aws.StringValue(col.Type),
+				CatalogName: aws.StringValue(col.CatalogName),
+				SchemaName:  aws.StringValue(col.SchemaName),
+				TableName:   aws.StringValue(col.TableName),
+				Precision:   aws.Int64Value(col.Precision),
+				Scale:       aws.Int64Value(col.Scale),
+			})
+		}
+	}
+	for _, row := range out.ResultSet.Rows {
+		values := make([]*string, len(row.Data))
+		for i, datum := range row.Data {
+			values[i] = datum.VarCharValue
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result
+}
+
+func millis(ms int64) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
+
+// logRetries makes client log the requests it retries.
+func (l *queryLogger) logRetries(client *athena.Athena) {
+	if l == nil {
+		return
+	}
+
+	client.Handlers.AfterRetry.Swap(corehandlers.AfterRetryHandler.Name, request.NamedHandler{
+		Name: corehandlers.AfterRetryHandler.Name,
+		Fn: func(r *request.Request) {
+			err := r.Error
+			corehandlers.AfterRetryHandler.Fn(r)
+			// The handler clears the error of requests it's going to retry.
+			if err != nil && r.Error == nil {
+				l.log(r.Context(), slog.LevelWarn, "athena: retrying request",
+					"operation", r.Operation.Name,
+					"attempt", r.RetryCount,
+					"delay", r.RetryDelay,
+					"error", err,
+				)
+			}
+		},
+	})
+}
+
+// newSessionS3API returns the S3API the driver uses when Config.S3 isn't set.
+func newSessionS3API(sess *session.Session) S3API {
+	return NewV1S3API(s3.New(sess))
+}
+
+// NewV1S3API returns an S3API that calls S3 with an AWS SDK for Go v1
+// client, e.g. s3.New(session).
+func NewV1S3API(client s3iface.S3API) S3API {
+	return v1S3API{client: client}
+}
+
+type v1S3API struct {
+	client s3iface.S3API
+}
+
+func (a v1S3API) GetObject(ctx context.Context, input *S3GetObjectInput) (*S3GetObjectOutput, error) {
+	in := &s3.GetObjectInput{
+		Bucket: aws.String(input.Bucket),
+		Key:    aws.String(input.Key),
+	}
+	if input.Range != "" {
+		in.Range = aws.String(input.Range)
+	}
+	out, err := a.client.GetObjectWithContext(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return &S3GetObjectOutput{Body: out.Body}, nil
+}
+
+func (a v1S3API) HeadObject(ctx context.Context, input *S3HeadObjectInput) (*S3HeadObjectOutput, error) {
+	out, err := a.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(input.Bucket),
+		Key:    aws.String(input.Key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3HeadObjectOutput{ContentLength: aws.Int64Value(out.ContentLength)}, nil
+}
+
+func (a v1S3API) ListObjectsV2(ctx context.Context, input *S3ListObjectsInput) (*S3ListObjectsOutput, error) {
+	in := &s3.ListObjectsV2Input{
+		Bucket: aws.String(input.Bucket),
+		Prefix: aws.String(input.Prefix),
+	}
+	if input.ContinuationToken != "" {
+		in.ContinuationToken = aws.String(input.ContinuationToken)
+	}
+	out, err := a.client.ListObjectsV2WithContext(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	var result S3ListObjectsOutput
+	for _, object := range out.Contents {
+		result.Keys = append(result.Keys, aws.StringValue(object.Key))
+	}
+	if aws.BoolValue(out.IsTruncated) {
+		result.NextContinuationToken = aws.StringValue(out.NextContinuationToken)
+	}
+	return &result, nil
+}
+
+func (a v1S3API) DeleteObjects(ctx context.Context, input *S3DeleteObjectsInput) (*S3DeleteObjectsOutput, error) {
+	objects := make([]*s3.ObjectIdentifier, len(input.Keys))
+	for i, key := range input.Keys {
+		objects[i] = &s3.ObjectIdentifier{Key: aws.String(key)}
+	}
+	out, err := a.client.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(input.Bucket),
+		Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result S3DeleteObjectsOutput
+	for _, failed := range out.Errors {
+		result.Errors = append(result.Errors, S3DeleteError{
+			Key:     aws.StringValue(failed.Key),
+			Message: aws.StringValue(failed.Message),
+		})
+	}
+	return &result, nil
+}
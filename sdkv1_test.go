@@ -0,0 +1,163 @@
+package athena
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/athena"
+	"github.com/aws/aws-sdk-go/service/athena/athenaiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeV1Client struct {
+	athenaiface.AthenaAPI
+	started *athena.StartQueryExecutionInput
+}
+
+func (c *fakeV1Client) StartQueryExecutionWithContext(_ aws.Context, input *athena.StartQueryExecutionInput, _ ...request.Option) (*athena.StartQueryExecutionOutput, error) {
+	c.started = input
+	return &athena.StartQueryExecutionOutput{QueryExecutionId: aws.String("id")}, nil
+}
+
+func (c *fakeV1Client) GetQueryExecutionWithContext(_ aws.Context, input *athena.GetQueryExecutionInput, _ ...request.Option) (*athena.GetQueryExecutionOutput, error) {
+	return &athena.GetQueryExecutionOutput{QueryExecution: &athena.QueryExecution{
+		QueryExecutionId:    input.QueryExecutionId,
+		StatementType:       aws.String(athena.StatementTypeDml),
+		ResultConfiguration: &athena.ResultConfiguration{OutputLocation: aws.String("s3://results/id.csv")},
+		Status: &athena.QueryExecutionStatus{
+			State: aws.String(athena.QueryExecutionStateFailed),
+			AthenaError: &athena.AthenaError{
+				ErrorCategory: aws.Int64(2),
+				ErrorMessage:  aws.String("syntax error"),
+			},
+		},
+		Statistics: &athena.QueryExecutionStatistics{
+			DataScannedInBytes:         aws.Int64(1024),
+			TotalExecutionTimeInMillis: aws.Int64(1500),
+			ResultReuseInformation:     &athena.ResultReuseInformation{ReusedPreviousResult: aws.Bool(true)},
+		},
+	}}, nil
+}
+
+func (c *fakeV1Client) GetQueryResultsWithContext(_ aws.Context, input *athena.GetQueryResultsInput, _ ...request.Option) (*athena.GetQueryResultsOutput, error) {
+	return &athena.GetQueryResultsOutput{
+		NextToken: aws.String("next"),
+		ResultSet: &athena.ResultSet{
+			ResultSetMetadata: &athena.ResultSetMetadata{ColumnInfo: []*athena.ColumnInfo{
+				{Name: aws.String("n"), Type: aws.String("decimal"), Precision: aws.Int64(10), Scale: aws.Int64(2)},
+			}},
+			Rows: []*athena.Row{
+				{Data: []*athena.Datum{{VarCharValue: aws.String("1.00")}, {}}},
+			},
+		},
+	}, nil
+}
+
+func TestV1API(t *testing.T) {
+	client := &fakeV1Client{}
+	api := NewV1API(client)
+	ctx := context.Background()
+
+	out, err := api.StartQueryExecution(ctx, &StartQueryExecutionInput{
+		Query:             "SELECT 1",
+		Database:          "db",
+		OutputLocation:    "s3://results",
+		Encryption:        &Encryption{Option: EncryptionSSES3},
+		ResultReuseMaxAge: time.Hour,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "id", out.QueryID)
+	assert.Equal(t, &athena.StartQueryExecutionInput{
+		QueryString:           aws.String("SELECT 1"),
+		QueryExecutionContext: &athena.QueryExecutionContext{Database: aws.String("db")},
+		ResultConfiguration: &athena.ResultConfiguration{
+			OutputLocation:          aws.String("s3://results"),
+			EncryptionConfiguration: &athena.EncryptionConfiguration{EncryptionOption: aws.String(EncryptionSSES3)},
+		},
+		ResultReuseConfiguration: &athena.ResultReuseConfiguration{
+			ResultReuseByAgeConfiguration: &athena.ResultReuseByAgeConfiguration{
+				Enabled:         aws.Bool(true),
+				MaxAgeInMinutes: aws.Int64(60),
+			},
+		},
+	}, client.started)
+
+	execution, err := api.GetQueryExecution(ctx, &GetQueryExecutionInput{QueryID: "id"})
+	require.NoError(t, err)
+	assert.Equal(t, &QueryExecution{
+		QueryID:        "id",
+		StatementType:  StatementTypeDML,
+		OutputLocation: "s3://results/id.csv",
+		Status: QueryExecutionStatus{
+			State:       QueryStateFailed,
+			AthenaError: &AthenaError{ErrorCategory: 2, ErrorMessage: "syntax error"},
+		},
+		Statistics: QueryExecutionStatistics{
+			DataScannedInBytes: 1024,
+			TotalExecutionTime: 1500 * time.Millisecond,
+			ResultReused:       true,
+		},
+	}, execution)
+
+	results, err := api.GetQueryResults(ctx, &GetQueryResultsInput{QueryID: "id"})
+	require.NoError(t, err)
+	assert.Equal(t, "next", results.NextToken)
+	assert.Equal(t, []ColumnInfo{{Name: "n", Type: "decimal", Precision: 10, Scale: 2}}, results.Columns)
+	assert.Equal(t, [][]*string{{aws.String("1.00"), nil}}, results.Rows)
+}
+
+type fakeV1S3Client struct {
+	s3iface.S3API
+	deleted *s3.DeleteObjectsInput
+}
+
+func (c *fakeV1S3Client) GetObjectWithContext(_ aws.Context, input *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	body := aws.StringValue(input.Bucket) + "/" + aws.StringValue(input.Key) + " " + aws.StringValue(input.Range)
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func (c *fakeV1S3Client) ListObjectsV2WithContext(_ aws.Context, input *s3.ListObjectsV2Input, _ ...request.Option) (*s3.ListObjectsV2Output, error) {
+	return &s3.ListObjectsV2Output{
+		Contents:              []*s3.Object{{Key: aws.String(aws.StringValue(input.Prefix) + "a")}},
+		IsTruncated:           aws.Bool(input.ContinuationToken == nil),
+		NextContinuationToken: aws.String("next"),
+	}, nil
+}
+
+func (c *fakeV1S3Client) DeleteObjectsWithContext(_ aws.Context, input *s3.DeleteObjectsInput, _ ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	c.deleted = input
+	return &s3.DeleteObjectsOutput{Errors: []*s3.Error{{Key: aws.String("b"), Message: aws.String("denied")}}}, nil
+}
+
+func TestV1S3API(t *testing.T) {
+	client := &fakeV1S3Client{}
+	api := NewV1S3API(client)
+	ctx := context.Background()
+
+	got, err := api.GetObject(ctx, &S3GetObjectInput{Bucket: "bucket", Key: "key", Range: "bytes=0-9"})
+	require.NoError(t, err)
+	body, err := io.ReadAll(got.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "bucket/key bytes=0-9", string(body))
+
+	list, err := api.ListObjectsV2(ctx, &S3ListObjectsInput{Bucket: "bucket", Prefix: "prefix/"})
+	require.NoError(t, err)
+	assert.Equal(t, &S3ListObjectsOutput{Keys: []string{"prefix/a"}, NextContinuationToken: "next"}, list)
+	list, err = api.ListObjectsV2(ctx, &S3ListObjectsInput{Bucket: "bucket", Prefix: "prefix/", ContinuationToken: "next"})
+	require.NoError(t, err)
+	assert.Empty(t, list.NextContinuationToken, "the last page has no token")
+
+	deleted, err := api.DeleteObjects(ctx, &S3DeleteObjectsInput{Bucket: "bucket", Keys: []string{"a", "b"}})
+	require.NoError(t, err)
+	assert.Len(t, client.deleted.Delete.Objects, 2)
+	assert.True(t, aws.BoolValue(client.deleted.Delete.Quiet))
+	assert.Equal(t, []S3DeleteError{{Key: "b", Message: "denied"}}, deleted.Errors)
+}
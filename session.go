@@ -0,0 +1,53 @@
+package athena
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// newSession creates the AWS session for a Config without one, from its
+// Region, Profile, etc. Settings it leaves empty come from the SDK's defaults,
+// e.g. environment variables and ~/.aws/config.
+func (c *Config) newSession() (*session.Session, error) {
+	if c.RoleARN == "" && (c.ExternalID != "" || c.RoleSessionName != "") {
+		return nil, errors.New("external_id and role_session_name require role_arn")
+	}
+
+	opts := session.Options{Profile: c.Profile}
+	if c.Profile != "" {
+		// Profiles may set a region, role, etc. in ~/.aws/config.
+		opts.SharedConfigState = session.SharedConfigEnable
+	}
+	if c.Region != "" {
+		opts.Config.Region = aws.String(c.Region)
+	}
+	if c.MaxRetries != nil {
+		opts.Config.MaxRetries = aws.Int(*c.MaxRetries)
+	}
+	if c.HTTPTimeout > 0 {
+		opts.Config.HTTPClient = &http.Client{Timeout: c.HTTPTimeout}
+	}
+
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.RoleARN != "" {
+		creds := stscreds.NewCredentials(sess, c.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+			if c.ExternalID != "" {
+				p.ExternalID = aws.String(c.ExternalID)
+			}
+			if c.RoleSessionName != "" {
+				p.RoleSessionName = c.RoleSessionName
+			}
+		})
+		sess = sess.Copy(&aws.Config{Credentials: creds})
+	}
+
+	return sess, nil
+}
@@ -0,0 +1,34 @@
+package athena
+
+import "time"
+
+// QueryStats describes the work Athena did for a query.
+type QueryStats struct {
+	QueryID string
+
+	DataScannedInBytes int64
+
+	QueueTime             time.Duration
+	PlanningTime          time.Duration
+	EngineExecutionTime   time.Duration
+	ServiceProcessingTime time.Duration
+	TotalExecutionTime    time.Duration
+
+	// ResultReused is true if Athena answered the query with the results of
+	// a previous one rather than running it. See Config.ResultReuseMaxAge.
+	ResultReused bool
+}
+
+func newQueryStats(execution *QueryExecution) QueryStats {
+	s := execution.Statistics
+	return QueryStats{
+		QueryID:               execution.QueryID,
+		DataScannedInBytes:    s.DataScannedInBytes,
+		QueueTime:             s.QueryQueueTime,
+		PlanningTime:          s.QueryPlanningTime,
+		EngineExecutionTime:   s.EngineExecutionTime,
+		ServiceProcessingTime: s.ServiceProcessingTime,
+		TotalExecutionTime:    s.TotalExecutionTime,
+		ResultReused:          s.ResultReused,
+	}
+}
@@ -0,0 +1,236 @@
+package athena
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"iter"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Querier runs queries. *sql.DB, *sql.Conn and *sql.Tx implement it.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// QueryStructs runs a query and returns its rows as Ts, which must be structs
+// or pointers to structs. Columns are scanned into the fields of the same
+// name, regardless of their order: the name in a field's `athena:"name"` tag,
+// or else the field's own name, compared case-insensitively. Fields tagged
+// `athena:"-"` are skipped, and the fields of embedded structs are promoted.
+// Columns without a field are ignored, and fields without a column are left
+// zero.
+func QueryStructs[T any](ctx context.Context, db Querier, query string, args ...any) ([]T, error) {
+	return collectStructs(queryStructs[T](ctx, db, false, query, args))
+}
+
+// QueryStructsStrict is like QueryStructs(), but fails if the columns don't
+// match the fields of T one to one.
+func QueryStructsStrict[T any](ctx context.Context, db Querier, query string, args ...any) ([]T, error) {
+	return collectStructs(queryStructs[T](ctx, db, true, query, args))
+}
+
+// QueryStructsSeq is like QueryStructs(), but yields the rows one at a time as
+// they're read. It yields an error at most once, and then stops. The query
+// runs when iteration starts, and its rows are closed when it ends.
+func QueryStructsSeq[T any](ctx context.Context, db Querier, query string, args ...any) iter.Seq2[T, error] {
+	return queryStructs[T](ctx, db, false, query, args)
+}
+
+// QueryStructsSeqStrict is like QueryStructsSeq(), but fails if the columns
+// don't match the fields of T one to one.
+func QueryStructsSeqStrict[T any](ctx context.Context, db Querier, query string, args ...any) iter.Seq2[T, error] {
+	return queryStructs[T](ctx, db, true, query, args)
+}
+
+func collectStructs[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var results []T
+	for v, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, v)
+	}
+	return results, nil
+}
+
+func queryStructs[T any](ctx context.Context, db Querier, strict bool, query string, args []any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer rows.Close()
+
+		columns, err := rows.Columns()
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		plan, err := newScanPlan(reflect.TypeFor[T](), columns, strict)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		dest := make([]any, len(columns))
+		for rows.Next() {
+			var v T
+			plan.bind(reflect.ValueOf(&v).Elem(), dest)
+			if err := rows.Scan(dest...); err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// scanPlan says which field each column is scanned into.
+type scanPlan struct {
+	pointer bool
+	// fields holds the index of each column's field, or nil if it has none.
+	fields [][]int
+}
+
+func newScanPlan(t reflect.Type, columns []string, strict bool) (*scanPlan, error) {
+	plan := &scanPlan{}
+	if t.Kind() == reflect.Pointer {
+		plan.pointer = true
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot scan rows into %s, it must be a struct or a pointer to one", t)
+	}
+
+	fields := structFields(t)
+	matched := make(map[string]bool, len(columns))
+	var unmatched []string
+	for _, column := range columns {
+		name := strings.ToLower(column)
+		index, ok := fields.byName[name]
+		if !ok {
+			unmatched = append(unmatched, column)
+		}
+		matched[name] = true
+		plan.fields = append(plan.fields, index)
+	}
+	if !strict {
+		return plan, nil
+	}
+
+	var missing []string
+	for _, field := range fields.list {
+		if !matched[strings.ToLower(field.name)] {
+			missing = append(missing, field.name)
+		}
+	}
+
+	var errs []error
+	if len(unmatched) > 0 {
+		errs = append(errs, fmt.Errorf("columns without fields in %s: %s", t, strings.Join(unmatched, ", ")))
+	}
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("fields without columns in %s: %s", t, strings.Join(missing, ", ")))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// bind points dest at the fields of v, a T, that the columns are scanned
+// into.
+func (p *scanPlan) bind(v reflect.Value, dest []any) {
+	if p.pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	for i, index := range p.fields {
+		if index == nil {
+			dest[i] = new(any)
+			continue
+		}
+		dest[i] = v.FieldByIndex(index).Addr().Interface()
+	}
+}
+
+// structField is a field of a struct that a column can be scanned into.
+type structField struct {
+	// name is the column name from the field's tag, or else its own name.
+	name  string
+	index []int
+}
+
+// structFieldSet holds the fields of a struct that columns can be scanned
+// into.
+type structFieldSet struct {
+	// list holds the fields in the order they're declared.
+	list []structField
+	// byName holds the index of each field by its lower-cased name.
+	byName map[string][]int
+}
+
+var structFieldsCache sync.Map // reflect.Type -> *structFieldSet
+
+// structFields returns the fields of t that columns can be scanned into.
+func structFields(t reflect.Type) *structFieldSet {
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.(*structFieldSet)
+	}
+
+	var candidates []structField
+	addStructFields(&candidates, t, nil)
+
+	// Fields closer to the top shadow those of embedded structs.
+	fields := &structFieldSet{byName: map[string][]int{}}
+	for _, field := range candidates {
+		name := strings.ToLower(field.name)
+		if existing, ok := fields.byName[name]; !ok || len(existing) > len(field.index) {
+			fields.byName[name] = field.index
+		}
+	}
+	for _, field := range candidates {
+		if slices.Equal(fields.byName[strings.ToLower(field.name)], field.index) {
+			fields.list = append(fields.list, field)
+		}
+	}
+	structFieldsCache.Store(t, fields)
+	return fields
+}
+
+func addStructFields(fields *[]structField, t reflect.Type, parent []int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("athena")
+		if tag == "-" {
+			continue
+		}
+		index := append(append([]int(nil), parent...), i)
+
+		if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+			addStructFields(fields, field.Type, index)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		name := tag
+		if name == "" {
+			name = field.Name
+		}
+		*fields = append(*fields, structField{name: name, index: index})
+	}
+}
@@ -0,0 +1,110 @@
+package athena
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type person struct {
+	LastName string `athena:"last_name"`
+	First    string `athena:"first_name"`
+	Ignored  string `athena:"-"`
+}
+
+func TestQueryStructs(t *testing.T) {
+	db, err := Open(Config{
+		API: &mockAsyncAthenaClient{states: map[string][]string{
+			"select": {QueryStateSucceeded},
+		}},
+		Database:       "db",
+		OutputLocation: "s3://results",
+		PollFrequency:  time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	people, err := QueryStructs[person](ctx, db, "select")
+	require.NoError(t, err)
+	require.Len(t, people, 9)
+	for _, p := range people {
+		assert.Len(t, p.First, 10)
+		assert.Len(t, p.LastName, 10)
+		assert.Empty(t, p.Ignored)
+	}
+
+	pointers, err := QueryStructs[*person](ctx, db, "select")
+	require.NoError(t, err)
+	require.Len(t, pointers, 9)
+	assert.NotEmpty(t, pointers[0].First)
+
+	// Stopping early closes the rows.
+	n := 0
+	for p, err := range QueryStructsSeq[person](ctx, db, "select") {
+		require.NoError(t, err)
+		assert.NotEmpty(t, p.First)
+		if n++; n == 2 {
+			break
+		}
+	}
+	assert.Equal(t, 2, n)
+
+	type firstOnly struct{ First_Name string }
+	_, err = QueryStructs[firstOnly](ctx, db, "select")
+	assert.NoError(t, err)
+	_, err = QueryStructsStrict[firstOnly](ctx, db, "select")
+	assert.EqualError(t, err, "columns without fields in athena.firstOnly: last_name")
+
+	// Every missing field is reported, by its own name, in the order they're
+	// declared.
+	type withMore struct {
+		person
+		ZipCode string
+		Age     int    `athena:"Age"`
+		City    string `athena:"city"`
+	}
+	for i := 0; i < 5; i++ {
+		_, err = QueryStructsStrict[withMore](ctx, db, "select")
+		assert.EqualError(t, err, "fields without columns in athena.withMore: ZipCode, Age, city")
+	}
+	for p, err := range QueryStructsSeqStrict[withMore](ctx, db, "select") {
+		assert.Zero(t, p)
+		assert.EqualError(t, err, "fields without columns in athena.withMore: ZipCode, Age, city")
+	}
+
+	type mismatched struct {
+		First string `athena:"first_name"`
+		Age   int    `athena:"age"`
+	}
+	_, err = QueryStructsStrict[mismatched](ctx, db, "select")
+	assert.EqualError(t, err, "columns without fields in athena.mismatched: last_name\nfields without columns in athena.mismatched: age")
+
+	_, err = QueryStructs[string](ctx, db, "select")
+	assert.EqualError(t, err, "cannot scan rows into string, it must be a struct or a pointer to one")
+}
+
+func TestStructFields(t *testing.T) {
+	type inner struct {
+		ID   int
+		Name string `athena:"name"`
+	}
+	type outer struct {
+		inner
+		Name    string `athena:"NAME"`
+		private int
+	}
+	fields := structFields(reflect.TypeFor[outer]())
+	assert.Equal(t, []structField{
+		{name: "ID", index: []int{0, 0}},
+		{name: "NAME", index: []int{1}},
+	}, fields.list)
+	assert.Equal(t, map[string][]int{
+		"id":   {0, 0},
+		"name": {1},
+	}, fields.byName)
+}
@@ -0,0 +1,88 @@
+package athena
+
+import (
+	"context"
+	"sync"
+)
+
+// running holds every query the process is waiting on, so they can be
+// stopped when their connection is closed or the process shuts down.
+// Queries started with Client.StartQuery() aren't tracked, as they're
+// meant to outlive the process.
+var running = queryTracker{queries: make(map[string]trackedQuery)}
+
+type queryTracker struct {
+	mu      sync.Mutex
+	queries map[string]trackedQuery
+}
+
+type trackedQuery struct {
+	// conn is the connection that started the query.
+	conn *conn
+	// shared is set if the query's execution is shared by identical queries
+	// of other connections (see Config.DedupQueries), so that it's not
+	// stopped when conn is closed.
+	shared bool
+}
+
+func (t *queryTracker) add(c *conn, queryID string, shared bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queries[queryID] = trackedQuery{conn: c, shared: shared}
+}
+
+func (t *queryTracker) remove(queryID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.queries, queryID)
+}
+
+// stop stops every query matching filter, returning the first error Athena
+// returned, if any.
+func (t *queryTracker) stop(ctx context.Context, filter func(trackedQuery) bool) error {
+	t.mu.Lock()
+	queries := make(map[string]*conn)
+	for queryID, q := range t.queries {
+		if filter(q) {
+			queries[queryID] = q.conn
+			delete(t.queries, queryID)
+		}
+	}
+	t.mu.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(queries))
+	for queryID, c := range queries {
+		wg.Add(1)
+		go func(queryID string, c *conn) {
+			defer wg.Done()
+			errs <- c.athena.StopQueryExecution(ctx, &StopQueryExecutionInput{QueryID: queryID})
+		}(queryID, c)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown stops every query that connections opened by the driver are
+// waiting on. It's meant to be called when the process is about to exit,
+// e.g. on SIGTERM, so that queries don't keep running and billing.
+func (d *Driver) Shutdown(ctx context.Context) error {
+	return running.stop(ctx, func(q trackedQuery) bool {
+		return q.conn.driver == d
+	})
+}
+
+// Shutdown stops every query the process is waiting on, whichever driver
+// or Client started it. See Driver.Shutdown().
+func Shutdown(ctx context.Context) error {
+	return running.stop(ctx, func(trackedQuery) bool {
+		return true
+	})
+}
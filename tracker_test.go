@@ -0,0 +1,123 @@
+package athena
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startRunningQuery runs a query on c in the background and waits until
+// it's running on the mock. The returned channel receives the query's error.
+func startRunningQuery(t *testing.T, c *conn, query string) <-chan error {
+	errs := make(chan error, 1)
+	go func() {
+		_, err := c.QueryContext(context.Background(), query, nil)
+		errs <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		running.mu.Lock()
+		defer running.mu.Unlock()
+		_, ok := running.queries[query]
+		return ok
+	}, time.Second, time.Millisecond)
+
+	return errs
+}
+
+func TestConn_Close(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"close_1": {QueryStateRunning},
+		"close_2": {QueryStateRunning},
+	}}
+	c1 := &conn{athena: mock, pollFrequency: time.Millisecond}
+	c2 := &conn{athena: mock, pollFrequency: time.Millisecond}
+
+	errs1 := startRunningQuery(t, c1, "close_1")
+	errs2 := startRunningQuery(t, c2, "close_2")
+
+	require.NoError(t, c1.Close())
+	assert.Equal(t, context.Canceled, <-errs1)
+
+	mock.mu.Lock()
+	assert.Equal(t, []string{"close_1"}, mock.stopped, "only the closed connection's queries should be stopped")
+	mock.mu.Unlock()
+
+	require.NoError(t, c2.Close())
+	assert.Equal(t, context.Canceled, <-errs2)
+}
+
+func TestDriver_Shutdown(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"shutdown_1": {QueryStateRunning},
+		"shutdown_2": {QueryStateRunning},
+		"other":      {QueryStateRunning},
+	}}
+	d := &Driver{}
+	c1 := &conn{athena: mock, pollFrequency: time.Millisecond, driver: d}
+	c2 := &conn{athena: mock, pollFrequency: time.Millisecond, driver: d}
+	other := &conn{athena: mock, pollFrequency: time.Millisecond, driver: &Driver{}}
+
+	errs1 := startRunningQuery(t, c1, "shutdown_1")
+	errs2 := startRunningQuery(t, c2, "shutdown_2")
+	errsOther := startRunningQuery(t, other, "other")
+
+	require.NoError(t, d.Shutdown(context.Background()))
+	assert.Equal(t, context.Canceled, <-errs1)
+	assert.Equal(t, context.Canceled, <-errs2)
+
+	mock.mu.Lock()
+	assert.ElementsMatch(t, []string{"shutdown_1", "shutdown_2"}, mock.stopped)
+	mock.mu.Unlock()
+
+	require.NoError(t, Shutdown(context.Background()))
+	assert.Equal(t, context.Canceled, <-errsOther)
+}
+
+func TestConn_Close_Shared(t *testing.T) {
+	mock := &mockAsyncAthenaClient{states: map[string][]string{
+		"shared": {QueryStateRunning},
+	}}
+	var g queryGroup
+	c1 := &conn{athena: mock, pollFrequency: time.Millisecond, shared: &g}
+	c2 := &conn{athena: mock, pollFrequency: time.Millisecond, shared: &g}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	query := func(ctx context.Context, c *conn, errs chan<- error) {
+		_, err := c.QueryContext(ctx, "shared", nil)
+		errs <- err
+	}
+	errs1 := make(chan error, 1)
+	errs2 := make(chan error, 1)
+	go query(ctx1, c1, errs1)
+	require.Eventually(t, func() bool {
+		running.mu.Lock()
+		defer running.mu.Unlock()
+		_, ok := running.queries["shared"]
+		return ok
+	}, time.Second, time.Millisecond)
+	go query(ctx2, c2, errs2)
+	time.Sleep(10 * time.Millisecond)
+
+	// The connection that started the query is closed while c2 still waits.
+	cancel1()
+	assert.Equal(t, context.Canceled, <-errs1)
+	require.NoError(t, c1.Close())
+	time.Sleep(10 * time.Millisecond)
+	mock.mu.Lock()
+	assert.Empty(t, mock.stopped, "shared query stopped while a caller still waits on it")
+	mock.mu.Unlock()
+
+	cancel2()
+	assert.Equal(t, context.Canceled, <-errs2)
+	require.Eventually(t, func() bool {
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		return len(mock.stopped) == 1
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,575 @@
+package athena
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// ResultMode is how the driver gets the results of queries.
+type ResultMode string
+
+const (
+	// ResultModeAPI pages through results with Athena's GetQueryResults. It's
+	// the default.
+	ResultModeAPI ResultMode = "api"
+
+	// ResultModeUnload makes Athena UNLOAD the results of queries returning
+	// rows (SELECT, WITH, ...) to Parquet files under Config.UnloadLocation,
+	// which the driver reads from S3 and deletes once the rows are closed.
+	// It's much faster and cheaper for large results. Other statements run as
+	// with ResultModeAPI, and the results of queries run with it aren't
+	// cached, nor affected by PageSize or PrefetchPages. MaxRows and
+	// StopAtMaxRows apply as with ResultModeAPI, but Athena still unloads
+	// every row.
+	//
+	// Values are returned as with ResultModeAPI, except that tinyint is
+	// supported and array, map and row values are returned as JSON strings.
+	// Only the results of db.Query() are unloaded; Client.StartQuery()
+	// ignores the mode.
+	ResultModeUnload ResultMode = "unload"
+)
+
+func validateResultMode(mode ResultMode) error {
+	switch mode {
+	case "", ResultModeAPI, ResultModeUnload:
+		return nil
+	}
+	return fmt.Errorf("invalid result mode %q", mode)
+}
+
+// resultModeFor returns how the results of a query run with ctx are read.
+func (c *conn) resultModeFor(ctx context.Context) ResultMode {
+	if mode, ok := resultModeFromContext(ctx); ok {
+		return mode
+	}
+	return c.resultMode
+}
+
+// unloadLocationFor returns a new, empty S3 prefix for a query run with ctx to
+// UNLOAD its results to.
+func (c *conn) unloadLocationFor(ctx context.Context) (string, error) {
+	location := c.unloadLocation
+	if location == "" {
+		location = strings.TrimSuffix(c.outputLocationFor(ctx), "/") + "/unload"
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(location, "/") + "/" + hex.EncodeToString(id) + "/", nil
+}
+
+// unloadQuery wraps query in an UNLOAD of its results to location as Parquet.
+func unloadQuery(query, location string) string {
+	query = strings.TrimRight(strings.TrimSpace(query), "; \t\r\n")
+	location = strings.ReplaceAll(location, "'", "''")
+	return fmt.Sprintf("UNLOAD (%s) TO '%s' WITH (format = 'PARQUET')", query, location)
+}
+
+// runUnload runs query with ResultModeUnload.
+func (c *conn) runUnload(ctx context.Context, query string) (driver.Rows, error) {
+	if c.s3 == nil {
+		return nil, errors.New("unloading results requires Config.S3 or Config.Session")
+	}
+
+	location, err := c.unloadLocationFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, execution, err := c.run(ctx, unloadQuery(query, location))
+	if err != nil {
+		// Don't leave behind whatever the query wrote before it failed.
+		_ = deleteS3Prefix(context.WithoutCancel(ctx), c.s3, location)
+		return nil, err
+	}
+
+	// The rows are closed, calling OnClose, if they can't be opened.
+	return newUnloadRows(ctx, c.s3, execution, location, c.maxRowsFor(ctx), c.stopAtMaxRows, c.hooksFor(ctx))
+}
+
+// unloadRows reads the Parquet files a query's results were unloaded to.
+type unloadRows struct {
+	s3       S3API
+	queryID  string
+	location string
+	manifest string
+
+	ctx   context.Context
+	hooks Hooks
+	info  QueryInfo
+	pages int
+	read  int
+
+	maxRows       int64
+	stopAtMaxRows bool
+
+	files  []string
+	schema *arrow.Schema
+	reader pqarrow.RecordReader
+	record arrow.RecordBatch
+	row    int
+	closed bool
+}
+
+func newUnloadRows(ctx context.Context, s3API S3API, execution *QueryExecution, location string, maxRows int64, stopAtMaxRows bool, hooks Hooks) (*unloadRows, error) {
+	r := &unloadRows{
+		s3:            s3API,
+		queryID:       execution.QueryID,
+		location:      location,
+		ctx:           ctx,
+		hooks:         hooks,
+		info:          newQueryInfo(execution),
+		maxRows:       maxRows,
+		stopAtMaxRows: stopAtMaxRows,
+		manifest:      execution.Statistics.DataManifestLocation,
+	}
+
+	var err error
+	if r.manifest != "" {
+		r.files, err = readManifest(ctx, s3API, r.manifest)
+	} else {
+		r.files, err = listS3Prefix(ctx, s3API, location)
+	}
+	if err == nil && len(r.files) > 0 {
+		// Open the first file now, for the schema.
+		err = r.openNextFile()
+	}
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// QueryID returns the ID of the query the rows are the results of.
+func (r *unloadRows) QueryID() string {
+	return r.queryID
+}
+
+// Columns returns the names of the columns. There are none if the query
+// returned no rows, as Athena writes no files then.
+func (r *unloadRows) Columns() []string {
+	if r.schema == nil {
+		return nil
+	}
+	columns := make([]string, r.schema.NumFields())
+	for i, field := range r.schema.Fields() {
+		columns[i] = field.Name
+	}
+	return columns
+}
+
+// ColumnInfo describes the columns. Parquet files don't say where columns
+// come from, so only their names and types are known.
+func (r *unloadRows) ColumnInfo() []Column {
+	var columns []Column
+	for i, name := range r.Columns() {
+		columns = append(columns, Column{Name: name, Type: r.ColumnTypeDatabaseTypeName(i)})
+	}
+	return columns
+}
+
+func (r *unloadRows) ColumnTypeDatabaseTypeName(index int) string {
+	return athenaTypeName(r.schema.Field(index).Type)
+}
+
+func (r *unloadRows) Next(dest []driver.Value) error {
+	if r.maxRows > 0 && int64(r.read) >= r.maxRows {
+		return r.rowLimitReached()
+	}
+	for r.record == nil || r.row >= int(r.record.NumRows()) {
+		if err := r.nextRecord(); err != nil {
+			return err
+		}
+	}
+
+	for i, column := range r.record.Columns() {
+		value, err := arrowValue(column, r.row)
+		if err != nil {
+			return err
+		}
+		dest[i] = value
+	}
+	r.row++
+	r.read++
+	return nil
+}
+
+// rowsLeft returns how many more rows can be read before maxRows, or -1 if
+// there's no limit.
+func (r *unloadRows) rowsLeft() int64 {
+	if r.maxRows <= 0 {
+		return -1
+	}
+	return max(r.maxRows-int64(r.read), 0)
+}
+
+// rowLimitReached returns what reading a row past maxRows does. Whether there
+// are more rows may take opening the next file to tell.
+func (r *unloadRows) rowLimitReached() error {
+	if r.stopAtMaxRows {
+		return io.EOF
+	}
+	for r.record == nil || r.row >= int(r.record.NumRows()) {
+		if err := r.nextRecord(); err != nil {
+			return err
+		}
+	}
+	return &ErrRowLimitExceeded{QueryID: r.queryID, MaxRows: r.maxRows}
+}
+
+// nextRecord moves on to the next batch of rows, opening the next file if
+// needed.
+func (r *unloadRows) nextRecord() error {
+	for {
+		if r.closed {
+			return io.EOF
+		}
+		if r.reader != nil {
+			if r.reader.Next() {
+				r.record = r.reader.RecordBatch()
+				r.row = 0
+				return nil
+			}
+			if err := r.reader.Err(); err != nil && err != io.EOF {
+				return err
+			}
+			r.reader.Release()
+			r.reader = nil
+			r.record = nil
+		}
+
+		if len(r.files) == 0 {
+			return io.EOF
+		}
+		if err := r.openNextFile(); err != nil {
+			return err
+		}
+	}
+}
+
+// openNextFile downloads the next file and starts reading it.
+func (r *unloadRows) openNextFile() error {
+	location := r.files[0]
+	r.files = r.files[1:]
+
+	r.pages++
+	page := PageInfo{Number: r.pages, StartedAt: time.Now()}
+	reader, rows, err := openParquet(r.ctx, r.s3, location)
+	page.Duration = time.Since(page.StartedAt)
+	page.Rows = int(rows)
+	page.Err = err
+	r.hooks.page(r.ctx, r.info, page)
+	if err != nil {
+		return err
+	}
+
+	if r.schema == nil {
+		r.schema = reader.Schema()
+	}
+	r.reader = reader
+	return nil
+}
+
+// Close stops reading the results and deletes their files.
+func (r *unloadRows) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.reader != nil {
+		r.reader.Release()
+		r.reader = nil
+		r.record = nil
+	}
+	r.hooks.close(r.ctx, r.info, r.read)
+
+	// The files are deleted even if the query's context is done.
+	ctx := context.WithoutCancel(r.ctx)
+	err := deleteS3Prefix(ctx, r.s3, r.location)
+	if r.manifest != "" {
+		if bucket, key, parseErr := parseS3URL(r.manifest); parseErr == nil {
+			err = errors.Join(err, deleteS3Objects(ctx, r.s3, bucket, []string{key}))
+		}
+	}
+	return err
+}
+
+// openParquet opens the Parquet file at location, an S3 URL, and returns a
+// reader of its rows and how many there are. The file is read in ranges as
+// needed, rather than downloaded whole.
+func openParquet(ctx context.Context, s3API S3API, location string) (pqarrow.RecordReader, int64, error) {
+	object, err := openS3Object(ctx, s3API, location)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pf, err := file.NewParquetReader(object)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading %s: %w", location, err)
+	}
+	fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{BatchSize: 64 * 1024}, memory.DefaultAllocator)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading %s: %w", location, err)
+	}
+	reader, err := fr.GetRecordReader(ctx, nil, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading %s: %w", location, err)
+	}
+	return reader, pf.NumRows(), nil
+}
+
+// s3Object reads an S3 object with a ranged GetObject per ReadAt call.
+type s3Object struct {
+	ctx    context.Context
+	s3     S3API
+	bucket string
+	key    string
+}
+
+// openS3Object returns a reader of the S3 object at location, an S3 URL.
+func openS3Object(ctx context.Context, s3API S3API, location string) (*io.SectionReader, error) {
+	bucket, key, err := parseS3URL(location)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := s3API.HeadObject(ctx, &S3HeadObjectInput{Bucket: bucket, Key: key})
+	if err != nil {
+		return nil, err
+	}
+
+	object := &s3Object{ctx: ctx, s3: s3API, bucket: bucket, key: key}
+	return io.NewSectionReader(object, 0, out.ContentLength), nil
+}
+
+func (o *s3Object) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	out, err := o.s3.GetObject(o.ctx, &S3GetObjectInput{
+		Bucket: o.bucket,
+		Key:    o.key,
+		Range:  fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer out.Body.Close()
+
+	n, err := io.ReadFull(out.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// readManifest returns the S3 URLs of the files listed in a query's data
+// manifest, one per line.
+func readManifest(ctx context.Context, s3API S3API, location string) ([]string, error) {
+	data, err := getS3Object(ctx, s3API, location)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+func getS3Object(ctx context.Context, s3API S3API, location string) ([]byte, error) {
+	bucket, key, err := parseS3URL(location)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := s3API.GetObject(ctx, &S3GetObjectInput{Bucket: bucket, Key: key})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+// listS3Prefix returns the S3 URLs of the objects under prefix, an S3 URL.
+func listS3Prefix(ctx context.Context, s3API S3API, prefix string) ([]string, error) {
+	bucket, keyPrefix, err := parseS3URL(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	input := &S3ListObjectsInput{Bucket: bucket, Prefix: keyPrefix}
+	for {
+		out, err := s3API.ListObjectsV2(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range out.Keys {
+			files = append(files, "s3://"+bucket+"/"+key)
+		}
+		if out.NextContinuationToken == "" {
+			return files, nil
+		}
+		input.ContinuationToken = out.NextContinuationToken
+	}
+}
+
+// deleteS3Prefix deletes every object under prefix, an S3 URL.
+func deleteS3Prefix(ctx context.Context, s3API S3API, prefix string) error {
+	files, err := listS3Prefix(ctx, s3API, prefix)
+	if err != nil {
+		return err
+	}
+
+	bucket, _, _ := parseS3URL(prefix)
+	keys := make([]string, len(files))
+	for i, f := range files {
+		_, keys[i], _ = parseS3URL(f)
+	}
+	return deleteS3Objects(ctx, s3API, bucket, keys)
+}
+
+// maxDeleteObjects is the most objects S3 deletes per request.
+const maxDeleteObjects = 1000
+
+func deleteS3Objects(ctx context.Context, s3API S3API, bucket string, keys []string) error {
+	for len(keys) > 0 {
+		batch := keys
+		if len(batch) > maxDeleteObjects {
+			batch = batch[:maxDeleteObjects]
+		}
+		keys = keys[len(batch):]
+
+		out, err := s3API.DeleteObjects(ctx, &S3DeleteObjectsInput{Bucket: bucket, Keys: batch})
+		if err != nil {
+			return err
+		}
+		if len(out.Errors) > 0 {
+			failed := out.Errors[0]
+			return fmt.Errorf("deleting s3://%s/%s: %s", bucket, failed.Key, failed.Message)
+		}
+	}
+	return nil
+}
+
+// parseS3URL splits an S3 URL, s3://bucket/key, into its bucket and key.
+func parseS3URL(location string) (bucket, key string, err error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid S3 URL %q", location)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// athenaTypeName returns the name Athena gives to the type of a column
+// unloaded as t.
+func athenaTypeName(t arrow.DataType) string {
+	switch t.ID() {
+	case arrow.INT8:
+		return "tinyint"
+	case arrow.INT16:
+		return "smallint"
+	case arrow.INT32:
+		return "integer"
+	case arrow.INT64:
+		return "bigint"
+	case arrow.FLOAT32:
+		return "float"
+	case arrow.FLOAT64:
+		return "double"
+	case arrow.BOOL:
+		return "boolean"
+	case arrow.STRING, arrow.LARGE_STRING:
+		return "varchar"
+	case arrow.BINARY, arrow.LARGE_BINARY:
+		return "varbinary"
+	case arrow.DECIMAL128, arrow.DECIMAL256:
+		return "decimal"
+	case arrow.DATE32, arrow.DATE64:
+		return "date"
+	case arrow.TIMESTAMP:
+		return "timestamp"
+	case arrow.LIST, arrow.LARGE_LIST, arrow.FIXED_SIZE_LIST:
+		return "array"
+	case arrow.MAP:
+		return "map"
+	case arrow.STRUCT:
+		return "row"
+	default:
+		return t.Name()
+	}
+}
+
+// arrowValue returns the value of column at row as the driver.Value
+// convertValue() would have returned for Athena's text rendering of it.
+func arrowValue(column arrow.Array, row int) (driver.Value, error) {
+	if column.IsNull(row) {
+		return nil, nil
+	}
+
+	switch c := column.(type) {
+	case *array.Int8:
+		return int64(c.Value(row)), nil
+	case *array.Int16:
+		return int64(c.Value(row)), nil
+	case *array.Int32:
+		return int64(c.Value(row)), nil
+	case *array.Int64:
+		return c.Value(row), nil
+	case *array.Float32:
+		return float64(c.Value(row)), nil
+	case *array.Float64:
+		return c.Value(row), nil
+	case *array.Boolean:
+		return c.Value(row), nil
+	case *array.String:
+		return c.Value(row), nil
+	case *array.LargeString:
+		return c.Value(row), nil
+	case *array.Binary:
+		// The value is only valid until the batch is released.
+		return bytes.Clone(c.Value(row)), nil
+	case *array.Decimal128:
+		return c.Value(row).ToFloat64(c.DataType().(*arrow.Decimal128Type).Scale), nil
+	case *array.Decimal256:
+		return c.Value(row).ToFloat64(c.DataType().(*arrow.Decimal256Type).Scale), nil
+	case *array.Date32:
+		return c.Value(row).ToTime(), nil
+	case *array.Date64:
+		return c.Value(row).ToTime(), nil
+	case *array.Timestamp:
+		return c.Value(row).ToTime(c.DataType().(*arrow.TimestampType).Unit), nil
+	case *array.List, *array.LargeList, *array.FixedSizeList, *array.Map, *array.Struct:
+		data, err := json.Marshal(column.GetOneForMarshal(row))
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	default:
+		return nil, fmt.Errorf("unsupported Parquet column type %s", column.DataType())
+	}
+}
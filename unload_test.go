@@ -0,0 +1,261 @@
+package athena
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockS3Client keeps objects in memory, keyed by their S3 URL.
+type mockS3Client struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	// gets holds the range of every GetObject call, or "" if it had none.
+	gets []string
+}
+
+func (m *mockS3Client) put(location string, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.objects == nil {
+		m.objects = map[string][]byte{}
+	}
+	m.objects[location] = data
+}
+
+func (m *mockS3Client) GetObject(_ context.Context, input *S3GetObjectInput) (*S3GetObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects["s3://"+input.Bucket+"/"+input.Key]
+	if !ok {
+		return nil, dummyError
+	}
+	m.gets = append(m.gets, input.Range)
+	if input.Range != "" {
+		var start, end int
+		if _, err := fmt.Sscanf(input.Range, "bytes=%d-%d", &start, &end); err != nil {
+			return nil, err
+		}
+		data = data[start:min(end+1, len(data))]
+	}
+	return &S3GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (m *mockS3Client) HeadObject(_ context.Context, input *S3HeadObjectInput) (*S3HeadObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects["s3://"+input.Bucket+"/"+input.Key]
+	if !ok {
+		return nil, dummyError
+	}
+	return &S3HeadObjectOutput{ContentLength: int64(len(data))}, nil
+}
+
+func (m *mockS3Client) ListObjectsV2(_ context.Context, input *S3ListObjectsInput) (*S3ListObjectsOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prefix := "s3://" + input.Bucket + "/"
+	var out S3ListObjectsOutput
+	for location := range m.objects {
+		if strings.HasPrefix(location, prefix+input.Prefix) {
+			out.Keys = append(out.Keys, strings.TrimPrefix(location, prefix))
+		}
+	}
+	return &out, nil
+}
+
+func (m *mockS3Client) DeleteObjects(_ context.Context, input *S3DeleteObjectsInput) (*S3DeleteObjectsOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range input.Keys {
+		delete(m.objects, "s3://"+input.Bucket+"/"+key)
+	}
+	return &S3DeleteObjectsOutput{}, nil
+}
+
+var unloadSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "id", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+	{Name: "price", Type: &arrow.Decimal128Type{Precision: 10, Scale: 2}},
+	{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+	{Name: "created", Type: &arrow.TimestampType{Unit: arrow.Microsecond}},
+}, nil)
+
+func genParquet(t *testing.T, rows string) []byte {
+	record, _, err := array.RecordFromJSON(memory.DefaultAllocator, unloadSchema, strings.NewReader(rows))
+	require.NoError(t, err)
+	defer record.Release()
+
+	table := array.NewTableFromRecords(unloadSchema, []arrow.RecordBatch{record})
+	defer table.Release()
+
+	var buf bytes.Buffer
+	require.NoError(t, pqarrow.WriteTable(table, &buf, 1024, nil, pqarrow.DefaultWriterProps()))
+	return buf.Bytes()
+}
+
+// mockUnloadAthenaClient runs UNLOAD queries by writing files for them to
+// its S3 client.
+type mockUnloadAthenaClient struct {
+	AthenaAPI
+	s3      *mockS3Client
+	files   [][]byte
+	started []string
+}
+
+var unloadLocationRegexp = regexp.MustCompile(`TO '([^']+)'`)
+
+func (m *mockUnloadAthenaClient) StartQueryExecution(_ context.Context, input *StartQueryExecutionInput) (*StartQueryExecutionOutput, error) {
+	m.started = append(m.started, input.Query)
+	location := unloadLocationRegexp.FindStringSubmatch(input.Query)[1]
+
+	var manifest []string
+	for i, data := range m.files {
+		file := location + "part-" + string(rune('0'+i)) + ".parquet"
+		m.s3.put(file, data)
+		manifest = append(manifest, file)
+	}
+	m.s3.put("s3://results/unload-manifest.csv", []byte(strings.Join(manifest, "\n")+"\n"))
+	return &StartQueryExecutionOutput{QueryID: "unload"}, nil
+}
+
+func (m *mockUnloadAthenaClient) GetQueryExecution(_ context.Context, input *GetQueryExecutionInput) (*QueryExecution, error) {
+	return &QueryExecution{
+		QueryID:       input.QueryID,
+		StatementType: StatementTypeDML,
+		Statistics: QueryExecutionStatistics{
+			DataManifestLocation: "s3://results/unload-manifest.csv",
+		},
+		Status: QueryExecutionStatus{
+			State: QueryStateSucceeded,
+		},
+	}, nil
+}
+
+func TestConn_Unload(t *testing.T) {
+	s3Client := &mockS3Client{}
+	mock := &mockUnloadAthenaClient{
+		s3: s3Client,
+		files: [][]byte{
+			genParquet(t, `[
+				{"id": 1, "name": "a", "price": "12.34", "tags": ["x", "y"], "created": "2024-01-02T03:04:05.000006"},
+				{"id": 2, "name": null, "price": "0.50", "tags": [], "created": "2024-01-02T00:00:00"}
+			]`),
+			genParquet(t, `[
+				{"id": 3, "name": "c", "price": "-1.00", "tags": ["z"], "created": "2024-01-03T00:00:00"}
+			]`),
+		},
+	}
+	c := &conn{
+		athena:         mock,
+		s3:             s3Client,
+		OutputLocation: "s3://results",
+		pollFrequency:  time.Millisecond,
+		resultMode:     ResultModeUnload,
+	}
+
+	rows, err := c.QueryContext(context.Background(), "SELECT * FROM t;", nil)
+	require.NoError(t, err)
+	require.Len(t, mock.started, 1)
+	assert.Regexp(t, `^UNLOAD \(SELECT \* FROM t\) TO 's3://results/unload/[0-9a-f]{32}/' WITH \(format = 'PARQUET'\)$`, mock.started[0])
+
+	assert.Equal(t, []string{"id", "name", "price", "tags", "created"}, rows.Columns())
+	types := rows.(driver.RowsColumnTypeDatabaseTypeName)
+	assert.Equal(t, "integer", types.ColumnTypeDatabaseTypeName(0))
+	assert.Equal(t, "decimal", types.ColumnTypeDatabaseTypeName(2))
+	assert.Equal(t, "array", types.ColumnTypeDatabaseTypeName(3))
+
+	var got [][]driver.Value
+	for {
+		dest := make([]driver.Value, 5)
+		err := rows.Next(dest)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, dest)
+	}
+	assert.Equal(t, [][]driver.Value{
+		{int64(1), "a", 12.34, `["x","y"]`, time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)},
+		{int64(2), nil, 0.5, `[]`, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{int64(3), "c", -1.0, `["z"]`, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}, got)
+
+	require.NoError(t, rows.Close())
+	assert.Empty(t, s3Client.objects, "results weren't cleaned up")
+
+	// Only the manifest is downloaded whole, the files are read in ranges.
+	assert.Equal(t, "", s3Client.gets[0])
+	for _, r := range s3Client.gets[1:] {
+		assert.NotEmpty(t, r)
+	}
+}
+
+func TestConn_Unload_MaxRows(t *testing.T) {
+	tests := []struct {
+		maxRows int64
+		stop    bool
+		ids     []int64
+		err     error
+	}{
+		{maxRows: 2, ids: []int64{1, 2}, err: &ErrRowLimitExceeded{QueryID: "unload", MaxRows: 2}},
+		{maxRows: 2, stop: true, ids: []int64{1, 2}, err: io.EOF},
+		{maxRows: 3, ids: []int64{1, 2, 3}, err: io.EOF},
+		{maxRows: 1, ids: []int64{1}, err: &ErrRowLimitExceeded{QueryID: "unload", MaxRows: 1}},
+	}
+	for _, test := range tests {
+		s3Client := &mockS3Client{}
+		c := &conn{
+			athena: &mockUnloadAthenaClient{
+				s3: s3Client,
+				files: [][]byte{
+					genParquet(t, `[
+						{"id": 1, "name": "a", "price": "1.00", "tags": [], "created": "2024-01-02T00:00:00"},
+						{"id": 2, "name": "b", "price": "2.00", "tags": [], "created": "2024-01-02T00:00:00"}
+					]`),
+					genParquet(t, `[{"id": 3, "name": "c", "price": "3.00", "tags": [], "created": "2024-01-02T00:00:00"}]`),
+				},
+			},
+			s3:             s3Client,
+			OutputLocation: "s3://results",
+			pollFrequency:  time.Millisecond,
+			resultMode:     ResultModeUnload,
+			stopAtMaxRows:  test.stop,
+		}
+
+		rows, err := c.QueryContext(WithMaxRows(context.Background(), test.maxRows), "SELECT * FROM t", nil)
+		require.NoError(t, err)
+		var ids []int64
+		for {
+			dest := make([]driver.Value, 5)
+			if err = rows.Next(dest); err != nil {
+				break
+			}
+			ids = append(ids, dest[0].(int64))
+		}
+		assert.Equal(t, test.ids, ids, "max rows %d", test.maxRows)
+		assert.Equal(t, test.err, err, "max rows %d", test.maxRows)
+		require.NoError(t, rows.Close())
+	}
+}
+
+func TestUnloadQuery(t *testing.T) {
+	assert.Equal(t,
+		`UNLOAD (SELECT 'a;') TO 's3://bucket/it''s/' WITH (format = 'PARQUET')`,
+		unloadQuery(" SELECT 'a;' ;\n", "s3://bucket/it's/"),
+	)
+}
@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"strconv"
 	"time"
-
-	"github.com/aws/aws-sdk-go/service/athena"
 )
 
 const (
@@ -16,9 +14,9 @@ const (
 	DateLayout                  = "2006-01-02"
 )
 
-func convertRow(columns []*athena.ColumnInfo, in []*athena.Datum, ret []driver.Value) error {
+func convertRow(columns []ColumnInfo, in []*string, ret []driver.Value) error {
 	for i, val := range in {
-		coerced, err := convertValue(*columns[i].Type, val.VarCharValue)
+		coerced, err := convertValue(columns[i].Type, val)
 		if err != nil {
 			return err
 		}